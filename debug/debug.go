@@ -0,0 +1,139 @@
+// Package debug exposes this package's built-in introspection endpoints —
+// expvar, pprof, environment info, in-flight request tracing, and varz/
+// Prometheus metrics (see sp.com.au/exp/varz) — behind an access check
+// suitable for mounting even in a production service.
+package debug
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"time"
+
+	httpctx "sp.com.au/exp"
+	"sp.com.au/exp/env"
+	"sp.com.au/exp/varz"
+)
+
+// AllowFunc decides whether r may access the debug handler. See
+// WithAllowFunc and DefaultAllowFunc.
+type AllowFunc func(r *http.Request) bool
+
+// SharedSecretHeader is the header DefaultAllowFunc checks for a
+// shared-secret bypass. See WithSharedSecret.
+const SharedSecretHeader = "X-Debug-Secret"
+
+// options holds the configuration built up by Option functions passed to
+// Handler.
+type options struct {
+	allow  AllowFunc
+	secret string
+}
+
+// Option configures Handler.
+type Option func(*options)
+
+// WithAllowFunc replaces DefaultAllowFunc with f entirely.
+func WithAllowFunc(f AllowFunc) Option {
+	return func(o *options) { o.allow = f }
+}
+
+// WithSharedSecret makes DefaultAllowFunc also permit any request that
+// carries secret in the SharedSecretHeader header, in addition to its
+// usual loopback and private-network check. It has no effect once
+// WithAllowFunc has replaced the default.
+func WithSharedSecret(secret string) Option {
+	return func(o *options) { o.secret = secret }
+}
+
+// Handler returns a http.Handler serving /debug/vars, /debug/pprof/*,
+// /debug/env and /debug/requests, gated by an AllowFunc (DefaultAllowFunc,
+// optionally widened with WithSharedSecret, unless WithAllowFunc is used).
+func Handler(opts ...Option) http.Handler {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	allow := o.allow
+	if allow == nil {
+		allow = defaultAllowFunc(o.secret)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/env", envHandler)
+	mux.HandleFunc("/debug/requests", requestsHandler)
+	mux.Handle("/debug/varz", varz.MetricsHandler(""))
+	mux.Handle("/metrics", varz.MetricsHandler(""))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allow(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// envHandler prints the process's environment name, as reported by the
+// env package.
+func envHandler(w http.ResponseWriter, _ *http.Request) {
+	fmt.Fprintf(w, "name: %s\n", env.Name())
+	fmt.Fprintf(w, "production: %t\n", env.IsProduction())
+	fmt.Fprintf(w, "test: %t\n", env.IsTest())
+	fmt.Fprintf(w, "development: %t\n", env.IsDevelopment())
+}
+
+// requestsHandler lists every request currently in flight through a
+// context created by this package (see httpctx.InFlightRequests), oldest
+// first.
+func requestsHandler(w http.ResponseWriter, _ *http.Request) {
+	infos := httpctx.InFlightRequests()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Started.Before(infos[j].Started) })
+
+	fmt.Fprintf(w, "%d in-flight request(s)\n", len(infos))
+	for _, info := range infos {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			info.Started.Format(time.RFC3339), info.Method, info.URL, info.RemoteAddr,
+			time.Since(info.Started).Truncate(time.Millisecond))
+	}
+}
+
+// DefaultAllowFunc permits requests from loopback and private-network
+// (RFC 1918 and friends, via net.IP.IsPrivate) addresses. It is used by
+// Handler unless overridden with WithAllowFunc or widened with
+// WithSharedSecret.
+func DefaultAllowFunc(r *http.Request) bool {
+	return defaultAllowFunc("")(r)
+}
+
+func defaultAllowFunc(secret string) AllowFunc {
+	return func(r *http.Request) bool {
+		if secret != "" && r.Header.Get(SharedSecretHeader) == secret {
+			return true
+		}
+		return isAllowedAddr(r.RemoteAddr)
+	}
+}
+
+// isAllowedAddr reports whether remoteAddr (as found in a http.Request's
+// RemoteAddr field, typically "host:port") is loopback or private.
+func isAllowedAddr(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	return ip.IsLoopback() || ip.IsPrivate()
+}
@@ -0,0 +1,73 @@
+package debug_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sp.com.au/exp/debug"
+)
+
+func TestHandlerRejectsDisallowedRemoteAddr(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+	r.RemoteAddr = "203.0.113.1:1234"
+	w := httptest.NewRecorder()
+
+	debug.Handler().ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestHandlerAllowsLoopback(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/debug/env", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	w := httptest.NewRecorder()
+
+	debug.Handler().ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	if w.Body.Len() == 0 {
+		t.Error("expected a non-empty /debug/env response")
+	}
+}
+
+func TestHandlerWithSharedSecret(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+	r.RemoteAddr = "203.0.113.1:1234"
+	r.Header.Set(debug.SharedSecretHeader, "s3cret")
+	w := httptest.NewRecorder()
+
+	debug.Handler(debug.WithSharedSecret("s3cret")).ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestHandlerWithAllowFuncOverride(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+	r.RemoteAddr = "203.0.113.1:1234"
+	w := httptest.NewRecorder()
+
+	debug.Handler(debug.WithAllowFunc(func(*http.Request) bool { return true })).ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestHandlerRequestsEndpoint(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/debug/requests", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	w := httptest.NewRecorder()
+
+	debug.Handler().ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,109 @@
+package httpctx
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"sort"
+)
+
+// AllowDebugAccess decides whether r may reach the mux built by
+// NewDebugMux. See DefaultAllowDebugAccess.
+type AllowDebugAccess func(r *http.Request) bool
+
+// DebugSecretEnv names the environment variable DefaultAllowDebugAccess
+// reads, at the time each request is checked, for a shared secret that it
+// accepts via the DebugSecretHeader header as an alternative to a
+// loopback/private source address. Leave it unset to disable the bypass.
+const DebugSecretEnv = "HTTPCTX_DEBUG_SECRET"
+
+// DebugSecretHeader is the header DefaultAllowDebugAccess checks for the
+// secret named by DebugSecretEnv.
+const DebugSecretHeader = "X-Debug-Secret"
+
+// DefaultAllowDebugAccess permits a request whose RemoteAddr is loopback
+// or a private-network address, or one carrying the secret named by
+// DebugSecretEnv, if that environment variable is set, in the
+// DebugSecretHeader header. It deliberately ignores X-Forwarded-For and
+// similar headers: they are supplied by the client (or an untrusted
+// proxy) and so are not a safe basis for an access decision.
+func DefaultAllowDebugAccess(r *http.Request) bool {
+	if secret := os.Getenv(DebugSecretEnv); secret != "" && r.Header.Get(DebugSecretHeader) == secret {
+		return true
+	}
+	return isLoopbackOrPrivate(r.RemoteAddr)
+}
+
+// isLoopbackOrPrivate reports whether remoteAddr (as found in a
+// http.Request's RemoteAddr field, typically "host:port", for either
+// IPv4 or IPv6) is loopback or private.
+func isLoopbackOrPrivate(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	return ip.IsLoopback() || ip.IsPrivate()
+}
+
+// NewDebugMux returns a *http.ServeMux serving /debug/pprof/*,
+// /debug/vars (raw expvar JSON) and /debug/varz (a human-readable status
+// page listing the same variables), gated by allow (DefaultAllowDebugAccess
+// if omitted), and falling back to main for every other path. This gives
+// operators a one-liner to expose safe introspection endpoints without
+// hand-wiring net/http/pprof and expvar themselves:
+//
+//	http.ListenAndServe(":8080", httpctx.NewDebugMux(appHandler))
+func NewDebugMux(main http.Handler, allow ...AllowDebugAccess) *http.ServeMux {
+	a := DefaultAllowDebugAccess
+	if len(allow) > 0 && allow[0] != nil {
+		a = allow[0]
+	}
+
+	guard := func(f http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !a(r) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			f(w, r)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", guard(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", guard(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", guard(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", guard(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", guard(pprof.Trace))
+	mux.HandleFunc("/debug/vars", guard(expvar.Handler().ServeHTTP))
+	mux.HandleFunc("/debug/varz", guard(debugStatusPage))
+	if main != nil {
+		mux.Handle("/", main)
+	}
+
+	return mux
+}
+
+// debugStatusPage renders every variable registered with the expvar
+// package as a plain-text table, for a quick human-readable overview
+// (/debug/vars is the same data, but as raw JSON for tooling).
+func debugStatusPage(w http.ResponseWriter, _ *http.Request) {
+	var names []string
+	expvar.Do(func(kv expvar.KeyValue) {
+		names = append(names, kv.Key)
+	})
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%d expvar variable(s)\n\n", len(names))
+	for _, name := range names {
+		fmt.Fprintf(w, "%s\t%s\n", name, expvar.Get(name).String())
+	}
+}
@@ -0,0 +1,102 @@
+package httpctx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestDefaultAllowDebugAccess(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       bool
+	}{
+		{"IPv4 loopback", "127.0.0.1:54321", true},
+		{"IPv6 loopback", "[::1]:54321", true},
+		{"RFC1918 10/8", "10.1.2.3:54321", true},
+		{"RFC1918 192.168/16", "192.168.1.1:54321", true},
+		{"public IPv4", "203.0.113.5:54321", false},
+		{"public IPv6", "[2001:db8::1]:54321", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if got := DefaultAllowDebugAccess(r); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultAllowDebugAccessIgnoresXForwardedFor(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+	r.RemoteAddr = "203.0.113.5:54321"
+	r.Header.Set("X-Forwarded-For", "127.0.0.1")
+
+	if DefaultAllowDebugAccess(r) {
+		t.Error("expected a spoofed X-Forwarded-For header not to grant access")
+	}
+}
+
+func TestDefaultAllowDebugAccessSharedSecret(t *testing.T) {
+	os.Setenv(DebugSecretEnv, "s3cret")
+	defer os.Unsetenv(DebugSecretEnv)
+
+	r := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+	r.RemoteAddr = "203.0.113.5:54321"
+	r.Header.Set(DebugSecretHeader, "s3cret")
+
+	if !DefaultAllowDebugAccess(r) {
+		t.Error("expected the shared secret header to grant access")
+	}
+
+	r.Header.Set(DebugSecretHeader, "wrong")
+	if DefaultAllowDebugAccess(r) {
+		t.Error("expected the wrong secret to be rejected")
+	}
+}
+
+func TestNewDebugMuxRejectsAndAllows(t *testing.T) {
+	main := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	mux := NewDebugMux(main)
+
+	r := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+	r.RemoteAddr = "203.0.113.5:54321"
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	r.RemoteAddr = "127.0.0.1:54321"
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusTeapot {
+		t.Errorf("got status %d, want the main handler's %d", w.Code, http.StatusTeapot)
+	}
+}
+
+func TestNewDebugMuxWithCustomAllowFunc(t *testing.T) {
+	mux := NewDebugMux(nil, func(*http.Request) bool { return true })
+
+	r := httptest.NewRequest(http.MethodGet, "/debug/varz", nil)
+	r.RemoteAddr = "203.0.113.5:54321"
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,40 @@
+package httpctx
+
+import (
+	"context"
+	"net/http"
+)
+
+// ErrorEncoder translates an error returned by a Handler into a HTTP
+// response. It is called in place of the package's built-in JSON/text
+// error handling whenever a non-nil error reaches Handle, HandleFunc, or a
+// Stack built with WithErrorEncoder.
+type ErrorEncoder func(ctx context.Context, w http.ResponseWriter, r *http.Request, err error)
+
+// defaultErrorEncoder is used by Handle, HandleFunc, and any Stack that has
+// not called WithErrorEncoder. Set it with SetDefaultErrorEncoder.
+var defaultErrorEncoder ErrorEncoder = DefaultErrorEncoder
+
+// SetDefaultErrorEncoder replaces the package-level ErrorEncoder used by
+// Handle, HandleFunc, and any Stack that has not been given its own encoder
+// via WithErrorEncoder. Passing nil restores DefaultErrorEncoder.
+//
+// This should normally be called once, during program startup, before any
+// HTTP requests are served.
+func SetDefaultErrorEncoder(enc ErrorEncoder) {
+	if enc == nil {
+		enc = DefaultErrorEncoder
+	}
+	defaultErrorEncoder = enc
+}
+
+// DefaultErrorEncoder is the ErrorEncoder used unless overridden by
+// SetDefaultErrorEncoder or Stack.WithErrorEncoder. It reproduces this
+// package's original behaviour: a JSON envelope for requests that accept
+// JSON or that target "/api/", and a plain text error otherwise.
+func DefaultErrorEncoder(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	if onError != nil {
+		onError(r, err)
+	}
+	sendError(w, r, err)
+}
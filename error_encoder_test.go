@@ -0,0 +1,46 @@
+package httpctx_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spkg/httpctx"
+	"golang.org/x/net/context"
+)
+
+func TestStackWithErrorEncoderRoutesErrorsToCustomEncoder(t *testing.T) {
+	var gotErr error
+	custom := func(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+		gotErr = err
+		w.WriteHeader(http.StatusTeapot)
+	}
+
+	wantErr := httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return errBoom
+	})
+
+	stack := httpctx.Use(passThroughMiddleware).WithErrorEncoder(custom).Use(passThroughMiddleware)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	stack.Handle(wantErr).ServeHTTP(w, r)
+
+	if gotErr != errBoom {
+		t.Errorf("got error %v routed to the custom encoder, want %v", gotErr, errBoom)
+	}
+	if w.Code != http.StatusTeapot {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusTeapot)
+	}
+}
+
+var errBoom = errors.New("boom")
+
+// passThroughMiddleware calls through to the wrapped handler unchanged,
+// unlike middleware1/middleware2 in httpctx_test.go, which never invoke f.
+func passThroughMiddleware(f httpctx.Handler) httpctx.Handler {
+	return httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return f.ServeHTTPContext(ctx, w, r)
+	})
+}
@@ -2,9 +2,17 @@
 package errs
 
 import (
+	"errors"
 	"net/http"
+	"runtime"
+	"strings"
 )
 
+// packagePrefix is the prefix of the Function field of runtime.Frame
+// values that belong to this package, used by errorImpl.StackTrace to
+// trim the constructor's own frames from the reported stack.
+const packagePrefix = "sp.com.au/exp/errs."
+
 // Common errors
 var (
 	NotImplemented = New("not implemented", http.StatusNotImplemented)
@@ -22,50 +30,160 @@ type Error interface {
 	StatusCode() int
 }
 
+// StackTracer is implemented by errors that have captured a call stack at
+// the point they were created (see New and Wrap). Frames are resolved
+// lazily: the underlying program counters are captured cheaply at
+// construction time, and only turned into runtime.Frame values (with file,
+// line and function name) when StackTrace is called.
+type StackTracer interface {
+	// StackTrace returns the call stack captured when the error was
+	// created, innermost frame first, with any frames inside this
+	// package itself removed.
+	StackTrace() []runtime.Frame
+}
+
 type errorImpl struct {
 	message    string
 	statusCode int
 	code       string
+	cause      error
+	pcs        []uintptr
 }
 
 // Error implements the error interface.
-func (e errorImpl) Error() string {
+func (e *errorImpl) Error() string {
 	return e.message
 }
 
 // StatusCode returns the suggested HTTP status code to return
 // to the HTTP client.
-func (e errorImpl) StatusCode() int {
+func (e *errorImpl) StatusCode() int {
 	return e.statusCode
 }
 
+// Unwrap returns the error that this error wraps, or nil if it does not
+// wrap another error. It allows errors.Is and errors.As to see through
+// errors created with Wrap.
+func (e *errorImpl) Unwrap() error {
+	return e.cause
+}
+
+// StackTrace implements StackTracer, resolving the captured program
+// counters into frames on demand.
+func (e *errorImpl) StackTrace() []runtime.Frame {
+	if len(e.pcs) == 0 {
+		return nil
+	}
+
+	frames := runtime.CallersFrames(e.pcs)
+	var stack []runtime.Frame
+	for {
+		frame, more := frames.Next()
+		if len(stack) == 0 && strings.HasPrefix(frame.Function, packagePrefix) {
+			// still inside a constructor in this package; keep skipping
+			// until we reach the caller's own frame.
+			if !more {
+				break
+			}
+			continue
+		}
+		stack = append(stack, frame)
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
 // BadRequest returns an errs.Error object with a HTTP status code
 // of Bad Request (400)
 func BadRequest(message string) error {
-	return New(message, http.StatusBadRequest)
+	return newError(message, http.StatusBadRequest, nil)
 }
 
 // Forbidden returns an errs.Error object with a HTTP status code
 // of Forbidden (403)
 func Forbidden(message string) error {
-	return New(message, http.StatusForbidden)
+	return newError(message, http.StatusForbidden, nil)
 }
 
 // ServerError returns an errs.Error object with a HTTP status code
 // of Internal Server Errror (500)
 func ServerError(message string) error {
-	return New(message, http.StatusInternalServerError)
+	return newError(message, http.StatusInternalServerError, nil)
 }
 
 // New returns an errs.Error object with the specified message and
-// HTTP status code.
+// HTTP status code. The call stack is captured at this point, and is
+// available via StackTrace.
 func New(message string, statusCode int) error {
-	return errorImpl{
+	return newError(message, statusCode, nil)
+}
+
+// Wrap returns a new error with message, wrapping err as its cause (see
+// Unwrap). The HTTP status code of err, if any, is preserved.
+//
+// If err (or any error in the chain it wraps) has not already captured a
+// call stack, Wrap captures one now; otherwise the existing stack is left
+// alone, so that wrapping an error repeatedly does not produce duplicate
+// or redundant stack traces.
+func Wrap(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+
+	e := &errorImpl{
+		message:    message,
+		statusCode: statusCodeOf(err),
+		cause:      err,
+	}
+	if !hasStackTrace(err) {
+		e.pcs = callers()
+	}
+	return e
+}
+
+// newError is the common constructor behind New, BadRequest, Forbidden
+// and ServerError. It always captures a stack, since these are the
+// innermost constructors an error chain can start from.
+func newError(message string, statusCode int, cause error) *errorImpl {
+	return &errorImpl{
 		message:    message,
 		statusCode: statusCode,
+		cause:      cause,
+		pcs:        callers(),
 	}
 }
 
+// callers captures the call stack, skipping the frames inside this
+// package so that the first frame reported belongs to the caller.
+func callers() []uintptr {
+	const maxDepth = 32
+	var pcs [maxDepth]uintptr
+	// skip runtime.Callers and callers itself; any remaining frames still
+	// inside this package (newError, Wrap, New, BadRequest, ...) are
+	// trimmed later by errorImpl.StackTrace.
+	n := runtime.Callers(2, pcs[:])
+	return pcs[:n]
+}
+
+// statusCodeOf returns the HTTP status code associated with err, via
+// errors.As, or 0 if none of the errors in its chain have one.
+func statusCodeOf(err error) int {
+	var se interface{ StatusCode() int }
+	if errors.As(err, &se) {
+		return se.StatusCode()
+	}
+	return 0
+}
+
+// hasStackTrace reports whether err, or any error in the chain it wraps,
+// already carries a captured call stack.
+func hasStackTrace(err error) bool {
+	var st StackTracer
+	return errors.As(err, &st)
+}
+
 // Code returns the code associated with the error, or a blank string
 // if the error does not have a code. Useful for AWS and other packages
 // that have error types with a Code() method.
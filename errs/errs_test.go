@@ -0,0 +1,120 @@
+package errs_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"sp.com.au/exp/errs"
+)
+
+// errsPackagePrefix mirrors errs.packagePrefix (unexported), for
+// asserting that StackTrace trims this package's own constructor
+// frames from the outside.
+const errsPackagePrefix = "sp.com.au/exp/errs."
+
+func TestNewCapturesStackTraceStartingAtCaller(t *testing.T) {
+	err := errs.New("boom", http.StatusTeapot)
+
+	st, ok := err.(errs.StackTracer)
+	if !ok {
+		t.Fatal("expected New to return a StackTracer")
+	}
+	frames := st.StackTrace()
+	if len(frames) == 0 {
+		t.Fatal("expected a non-empty stack trace")
+	}
+	if !strings.HasSuffix(frames[0].Function, "TestNewCapturesStackTraceStartingAtCaller") {
+		t.Errorf("got innermost frame %q, want this test's own frame", frames[0].Function)
+	}
+	for _, f := range frames {
+		if strings.HasPrefix(f.Function, errsPackagePrefix) {
+			t.Errorf("expected constructor frames to be trimmed, found %q", f.Function)
+		}
+	}
+}
+
+func TestErrorCarriesMessageAndStatusCode(t *testing.T) {
+	err := errs.New("bad input", http.StatusBadRequest)
+
+	var e errs.Error
+	if !errors.As(err, &e) {
+		t.Fatal("expected New to return an errs.Error")
+	}
+	if e.Error() != "bad input" {
+		t.Errorf("got message %q, want %q", e.Error(), "bad input")
+	}
+	if e.StatusCode() != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", e.StatusCode(), http.StatusBadRequest)
+	}
+}
+
+func TestWrapPreservesCauseStatusCodeAndUnwrap(t *testing.T) {
+	cause := errs.New("original", http.StatusNotFound)
+	wrapped := errs.Wrap(cause, "while looking up widget")
+
+	if wrapped.Error() != "while looking up widget" {
+		t.Errorf("got message %q, want %q", wrapped.Error(), "while looking up widget")
+	}
+	if errors.Unwrap(wrapped) != cause {
+		t.Error("expected Wrap to preserve cause via Unwrap")
+	}
+
+	var e errs.Error
+	if !errors.As(wrapped, &e) || e.StatusCode() != http.StatusNotFound {
+		t.Errorf("expected the wrapped error's status code to come from cause")
+	}
+}
+
+func TestWrapDoesNotDoubleCaptureStack(t *testing.T) {
+	cause := errs.New("original", http.StatusInternalServerError)
+	causeTrace := cause.(errs.StackTracer).StackTrace()
+
+	wrapped := errs.Wrap(cause, "again")
+	wrappedTrace := wrapped.(errs.StackTracer).StackTrace()
+
+	if len(wrappedTrace) != 0 {
+		t.Errorf("expected Wrap not to capture its own stack when cause already has one, got %d frames", len(wrappedTrace))
+	}
+	if len(causeTrace) == 0 {
+		t.Error("expected cause's own stack trace to remain intact")
+	}
+}
+
+func TestWrapCapturesStackWhenCauseHasNone(t *testing.T) {
+	cause := errors.New("plain error")
+	wrapped := errs.Wrap(cause, "wrapped")
+
+	st, ok := wrapped.(errs.StackTracer)
+	if !ok {
+		t.Fatal("expected Wrap to return a StackTracer")
+	}
+	if len(st.StackTrace()) == 0 {
+		t.Error("expected Wrap to capture a stack when cause has none")
+	}
+}
+
+func TestWrapOfNilReturnsNil(t *testing.T) {
+	if errs.Wrap(nil, "anything") != nil {
+		t.Error("expected Wrap(nil, ...) to return nil")
+	}
+}
+
+func TestBadRequestForbiddenServerErrorStatusCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errs.BadRequest("x"), http.StatusBadRequest},
+		{errs.Forbidden("x"), http.StatusForbidden},
+		{errs.ServerError("x"), http.StatusInternalServerError},
+		{errs.NotImplemented, http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		var e errs.Error
+		if !errors.As(tt.err, &e) || e.StatusCode() != tt.want {
+			t.Errorf("got status %v, want %d for %v", e, tt.want, tt.err)
+		}
+	}
+}
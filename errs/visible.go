@@ -0,0 +1,54 @@
+package errs
+
+import (
+	"errors"
+)
+
+// visibleError wraps a cause with a message and HTTP status code that are
+// safe to return to a HTTP client. The cause itself is only reachable via
+// Unwrap, so that logging and tracing middleware can still record it
+// without it leaking into the HTTP response.
+type visibleError struct {
+	message    string
+	statusCode int
+	cause      error
+}
+
+// Error implements the error interface, returning the client-safe message.
+func (e *visibleError) Error() string {
+	return e.message
+}
+
+// StatusCode returns the suggested HTTP status code to return to the
+// HTTP client.
+func (e *visibleError) StatusCode() int {
+	return e.statusCode
+}
+
+// Unwrap returns the internal cause, so that errors.Is and errors.As
+// continue to work across the visible/internal boundary.
+func (e *visibleError) Unwrap() error {
+	return e.cause
+}
+
+// Visible wraps cause with a message and HTTP status code that are safe to
+// show to a HTTP client. The cause is preserved via Unwrap, so that
+// server-side logging can still record the full internal detail, while
+// only msg and status ever reach the client.
+func Visible(msg string, status int, cause error) error {
+	return &visibleError{
+		message:    msg,
+		statusCode: status,
+		cause:      cause,
+	}
+}
+
+// AsVisible reports whether err, or an error it wraps, was created with
+// Visible, and if so returns the message intended for the HTTP client.
+func AsVisible(err error) (string, bool) {
+	var ve *visibleError
+	if errors.As(err, &ve) {
+		return ve.message, true
+	}
+	return "", false
+}
@@ -0,0 +1,51 @@
+package errs_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"sp.com.au/exp/errs"
+)
+
+func TestVisibleReturnsClientSafeMessageAndStatus(t *testing.T) {
+	cause := errors.New("internal detail the client should not see")
+	err := errs.Visible("something went wrong", http.StatusServiceUnavailable, cause)
+
+	if err.Error() != "something went wrong" {
+		t.Errorf("got message %q, want %q", err.Error(), "something went wrong")
+	}
+	var e errs.Error
+	if !errors.As(err, &e) || e.StatusCode() != http.StatusServiceUnavailable {
+		t.Errorf("expected status code %d", http.StatusServiceUnavailable)
+	}
+}
+
+func TestVisiblePreservesCauseViaUnwrap(t *testing.T) {
+	cause := errors.New("internal detail")
+	err := errs.Visible("public message", http.StatusInternalServerError, cause)
+
+	if !errors.Is(err, cause) {
+		t.Error("expected errors.Is to see through Visible to its cause")
+	}
+}
+
+func TestAsVisibleFindsMessageAcrossWrapChain(t *testing.T) {
+	visible := errs.Visible("try again later", http.StatusServiceUnavailable, errors.New("db down"))
+	wrapped := errs.Wrap(visible, "handling request")
+
+	msg, ok := errs.AsVisible(wrapped)
+	if !ok {
+		t.Fatal("expected AsVisible to find the Visible error in the chain")
+	}
+	if msg != "try again later" {
+		t.Errorf("got message %q, want %q", msg, "try again later")
+	}
+}
+
+func TestAsVisibleFalseWhenNotPresent(t *testing.T) {
+	_, ok := errs.AsVisible(errors.New("plain error"))
+	if ok {
+		t.Error("expected AsVisible to report false for a non-Visible error")
+	}
+}
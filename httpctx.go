@@ -46,6 +46,13 @@ func newContext(ctx context.Context, w http.ResponseWriter, r *http.Request) (co
 	// create a context without a timeout
 	ctx, cancelFunc = context.WithCancel(ctx)
 
+	untrack := trackRequest(r)
+	innerCancel := cancelFunc
+	cancelFunc = func() {
+		untrack()
+		innerCancel()
+	}
+
 	if closeNotifier, ok := w.(http.CloseNotifier); ok {
 		// need to acquire the channel prior to entering
 		// the go-routine, otherwise CloseNotify could be
@@ -86,20 +93,28 @@ func (f HandlerFunc) ServeHTTPContext(ctx context.Context, w http.ResponseWriter
 // HTTP handlers. A middleware function is any function that accepts a Handler as a
 // parameter and returns a Handler.
 type Stack struct {
-	middleware func(Handler) Handler
-	previous   *Stack
+	middleware   func(Handler) Handler
+	previous     *Stack
+	errorEncoder ErrorEncoder
 }
 
 // Handle converts a httpctx.Handler into a http.Handler.
 func Handle(h Handler) http.Handler {
+	return handle(h, defaultErrorEncoder)
+}
+
+// handle builds the http.Handler common to Handle and Stack.Handle, routing
+// any error returned by h through enc.
+func handle(h Handler, enc ErrorEncoder) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Note that if the handler h has been created using a stack (ie Context
 		// or Use functions), the first middleware in the stack will replace the context.
 		// Pass the background context here just in case h has been constructed a
 		// different way, but this will be rare.
-		err := h.ServeHTTPContext(context.Background(), w, r)
+		ctx := context.Background()
+		err := h.ServeHTTPContext(ctx, w, r)
 		if err != nil {
-			sendError(w, r, err)
+			enc(ctx, w, r, err)
 		}
 	})
 }
@@ -170,11 +185,37 @@ func (s *Stack) Use(f ...func(h Handler) Handler) *Stack {
 // Handle creates a http.Handler from a stack of middleware
 // functions and a httpctx.Handler.
 func (s *Stack) Handle(h Handler) http.Handler {
+	enc := s.errorEncoderOrDefault()
 	for stack := s; stack != nil; stack = stack.previous {
 		h = stack.middleware(h)
 	}
 
-	return Handle(h)
+	return handle(h, enc)
+}
+
+// WithErrorEncoder returns a new Stack that uses enc, instead of the
+// package-level default (see SetDefaultErrorEncoder), to translate any
+// error returned by a Handler built from this stack into a HTTP response.
+// The encoder also applies to any stack derived from the result via Use,
+// unless that stack calls WithErrorEncoder again.
+func (s *Stack) WithErrorEncoder(enc ErrorEncoder) *Stack {
+	return &Stack{
+		middleware:   func(h Handler) Handler { return h },
+		previous:     s,
+		errorEncoder: enc,
+	}
+}
+
+// errorEncoderOrDefault returns the nearest ErrorEncoder set via
+// WithErrorEncoder in the stack, or the package-level default if none of
+// the stacks in the chain have one.
+func (s *Stack) errorEncoderOrDefault() ErrorEncoder {
+	for stack := s; stack != nil; stack = stack.previous {
+		if stack.errorEncoder != nil {
+			return stack.errorEncoder
+		}
+	}
+	return defaultErrorEncoder
 }
 
 // HandleFunc returns a http.Handler (compatible with the standard library http package), which
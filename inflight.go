@@ -0,0 +1,57 @@
+package httpctx
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RequestInfo describes a request whose context was created by newContext
+// (that is, one passed through Context, and therefore through Use), for as
+// long as that context remains live. See InFlightRequests.
+type RequestInfo struct {
+	Method     string
+	URL        string
+	RemoteAddr string
+	Started    time.Time
+}
+
+// inFlight holds the RequestInfo for every request currently being served
+// through a context created by newContext, keyed by the *http.Request
+// itself. Entries are added in newContext and removed once the request's
+// context is cancelled.
+var inFlight sync.Map
+
+// InFlightRequests returns a snapshot of every request currently being
+// served through a context created by newContext (see Context). It is
+// intended for introspection tools such as httpctx/debug, not for use in
+// request handling itself.
+func InFlightRequests() []RequestInfo {
+	var infos []RequestInfo
+	inFlight.Range(func(_, v interface{}) bool {
+		infos = append(infos, v.(RequestInfo))
+		return true
+	})
+	return infos
+}
+
+// trackRequest records r as in-flight and returns a function that removes
+// it again; the caller must arrange for the returned function to run when
+// the context created alongside it is done, however that happens (normal
+// completion, client disconnect, or timeout).
+func trackRequest(r *http.Request) func() {
+	if r == nil {
+		return func() {}
+	}
+	var url string
+	if r.URL != nil {
+		url = r.URL.String()
+	}
+	inFlight.Store(r, RequestInfo{
+		Method:     r.Method,
+		URL:        url,
+		RemoteAddr: r.RemoteAddr,
+		Started:    time.Now(),
+	})
+	return func() { inFlight.Delete(r) }
+}
@@ -0,0 +1,42 @@
+package httpctx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewContextTracksAndUntracksRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+
+	_, cancel := newContext(context.Background(), httptest.NewRecorder(), r)
+
+	found := false
+	for _, info := range InFlightRequests() {
+		if info.Method == http.MethodGet && info.URL == "/widgets" && info.RemoteAddr == r.RemoteAddr {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected the request to appear in InFlightRequests")
+	}
+
+	cancel()
+
+	for _, info := range InFlightRequests() {
+		if info.URL == "/widgets" && info.RemoteAddr == r.RemoteAddr {
+			t.Fatal("expected the request to be removed from InFlightRequests after cancel")
+		}
+	}
+}
+
+func TestNewContextWithNilRequestDoesNotTrack(t *testing.T) {
+	before := len(InFlightRequests())
+	_, cancel := newContext(nil, nil, nil)
+	defer cancel()
+	if got := len(InFlightRequests()); got != before {
+		t.Errorf("got %d in-flight requests, want %d", got, before)
+	}
+}
@@ -0,0 +1,126 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// HTTPRequest describes one HTTP request, for inclusion as the
+// "httpRequest" field of a message written by UseJSONFormatter. See
+// httpctx.LogContext for how one is attached to a request's context.
+type HTTPRequest struct {
+	Method    string
+	URL       string
+	Status    int
+	Latency   time.Duration
+	RemoteIP  string
+	UserAgent string
+}
+
+// HTTPRequestKey is the name NewContext must be called with to attach an
+// *HTTPRequest to a context, so that UseJSONFormatter's output gives it
+// the special treatment Google Cloud Logging expects rather than
+// rendering it as an ordinary field. httpctx.LogContext does this.
+const HTTPRequestKey = "httpRequest"
+
+// cloudSeverity maps a Severity onto the severity names Google Cloud
+// Logging understands
+// (https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity).
+// It has no "fatal" tier, so SeverityFatal maps to the next one up.
+func cloudSeverity(s Severity) string {
+	switch s {
+	case SeverityDebug:
+		return "DEBUG"
+	case SeverityInfo:
+		return "INFO"
+	case SeverityWarning:
+		return "WARNING"
+	case SeverityError:
+		return "ERROR"
+	case SeverityFatal:
+		return "CRITICAL"
+	default:
+		return "DEFAULT"
+	}
+}
+
+// UseJSONFormatter sets Print to write each message to w as a
+// single-line JSON object following Google Cloud (Stackdriver)
+// structured logging conventions, so it can be ingested directly by a
+// hosted log aggregator: "severity", "time" (RFC3339 with nanoseconds),
+// "message", "logging.googleapis.com/sourceLocation" (the Debug/Info/
+// Warn/Error call site) and, if the context carries one (see
+// HTTPRequestKey), an "httpRequest" object. Every other parameter or
+// context value becomes its own top-level field.
+func UseJSONFormatter(w io.Writer) {
+	Print = func(m *Message) {
+		writeJSONMessage(w, m)
+	}
+}
+
+func writeJSONMessage(w io.Writer, m *Message) {
+	entry := make(map[string]interface{}, len(m.Parameters)+len(m.Context)+4)
+	entry["severity"] = cloudSeverity(m.Severity)
+	entry["time"] = m.Timestamp.Format(time.RFC3339Nano)
+	entry["message"] = m.Text
+	if m.Location.Function != "" {
+		entry["logging.googleapis.com/sourceLocation"] = map[string]string{
+			"file":     m.Location.File,
+			"line":     strconv.Itoa(m.Location.Line),
+			"function": m.Location.Function,
+		}
+	}
+	if m.Err != nil {
+		entry["error"] = m.Err.Error()
+	}
+	if len(m.Stack) > 0 {
+		frames := make([]string, len(m.Stack))
+		for i, f := range m.Stack {
+			frames[i] = fmt.Sprintf("%s:%d %s", f.File, f.Line, f.Function)
+		}
+		entry["stack"] = frames
+	}
+
+	for _, p := range m.Parameters {
+		entry[p.Name] = p.Value
+	}
+	for _, p := range m.Context {
+		if p.Name == HTTPRequestKey {
+			if req, ok := p.Value.(*HTTPRequest); ok {
+				entry[HTTPRequestKey] = jsonHTTPRequest(req)
+				continue
+			}
+		}
+		entry[p.Name] = p.Value
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Fprintf(w, "{\"severity\":\"ERROR\",\"message\":%q}\n", "log: failed to marshal entry: "+err.Error())
+		return
+	}
+	w.Write(b)
+	fmt.Fprintln(w)
+}
+
+// jsonHTTPRequest renders req using the field names Google Cloud Logging
+// documents for LogEntry.httpRequest. Status and Latency are omitted
+// when zero, since req may have been attached before either was known.
+func jsonHTTPRequest(req *HTTPRequest) map[string]interface{} {
+	out := map[string]interface{}{
+		"requestMethod": req.Method,
+		"requestUrl":    req.URL,
+		"remoteIp":      req.RemoteIP,
+		"userAgent":     req.UserAgent,
+	}
+	if req.Status != 0 {
+		out["status"] = req.Status
+	}
+	if req.Latency != 0 {
+		out["latency"] = fmt.Sprintf("%.9fs", req.Latency.Seconds())
+	}
+	return out
+}
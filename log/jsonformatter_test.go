@@ -0,0 +1,104 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestUseJSONFormatterWritesCloudLoggingFields(t *testing.T) {
+	var buf bytes.Buffer
+	restore := Print
+	defer func() { Print = restore }()
+	UseJSONFormatter(&buf)
+
+	Info("widget created", WithValue("widget_id", "w1"))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("output is not valid JSON: %v (%s)", err, buf.String())
+	}
+	if entry["severity"] != "INFO" {
+		t.Errorf("got severity %v, want INFO", entry["severity"])
+	}
+	if entry["message"] != "widget created" {
+		t.Errorf("got message %v, want %q", entry["message"], "widget created")
+	}
+	if entry["widget_id"] != "w1" {
+		t.Errorf("got widget_id %v, want w1", entry["widget_id"])
+	}
+	if _, ok := entry["time"].(string); !ok {
+		t.Error("expected a time field")
+	}
+	loc, ok := entry["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+	if !ok {
+		t.Fatal("expected a logging.googleapis.com/sourceLocation object")
+	}
+	if fn, _ := loc["function"].(string); fn == "" {
+		t.Error("expected a non-empty function in sourceLocation")
+	}
+}
+
+func TestUseJSONFormatterMapsSeverity(t *testing.T) {
+	tests := []struct {
+		severity Severity
+		want     string
+	}{
+		{SeverityDebug, "DEBUG"},
+		{SeverityInfo, "INFO"},
+		{SeverityWarning, "WARNING"},
+		{SeverityError, "ERROR"},
+		{SeverityFatal, "CRITICAL"},
+	}
+	for _, tt := range tests {
+		if got := cloudSeverity(tt.severity); got != tt.want {
+			t.Errorf("cloudSeverity(%v) = %q, want %q", tt.severity, got, tt.want)
+		}
+	}
+}
+
+func TestUseJSONFormatterIncludesError(t *testing.T) {
+	var buf bytes.Buffer
+	restore := Print
+	defer func() { Print = restore }()
+	UseJSONFormatter(&buf)
+
+	Error("save failed", WithError(errors.New("disk full")))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if entry["error"] != "disk full" {
+		t.Errorf("got error %v, want %q", entry["error"], "disk full")
+	}
+}
+
+func TestUseJSONFormatterRendersHTTPRequest(t *testing.T) {
+	var buf bytes.Buffer
+	restore := Print
+	defer func() { Print = restore }()
+	UseJSONFormatter(&buf)
+
+	req := &HTTPRequest{Method: "GET", URL: "/widgets", RemoteIP: "203.0.113.1", UserAgent: "test-agent"}
+	ctx := NewContext(context.Background(), HTTPRequestKey, req)
+	Info("handled request", WithContext(ctx))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	hr, ok := entry["httpRequest"].(map[string]interface{})
+	if !ok {
+		t.Fatal("expected an httpRequest object")
+	}
+	if hr["requestMethod"] != "GET" || hr["requestUrl"] != "/widgets" || hr["remoteIp"] != "203.0.113.1" {
+		t.Errorf("got httpRequest %+v, missing expected fields", hr)
+	}
+	if _, present := hr["status"]; present {
+		t.Error("did not expect a status field when Status is zero")
+	}
+}
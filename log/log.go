@@ -29,6 +29,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"runtime"
 	"time"
 )
 
@@ -46,6 +47,24 @@ type Message struct {
 	Context    []Parameter
 	Err        error
 	StatusCode int
+
+	// Stack is the call stack captured when Err was created, if Err (or
+	// any error it wraps) implements StackTrace() []runtime.Frame. See
+	// WithError.
+	Stack []runtime.Frame
+
+	// Location is where the log call (Debug, Info, Warn or Error) that
+	// created this message was made, for formatters that record it (see
+	// UseJSONFormatter).
+	Location SourceLocation
+}
+
+// SourceLocation identifies a line of source code, such as where a log
+// call originated.
+type SourceLocation struct {
+	File     string
+	Line     int
+	Function string
 }
 
 // Parameter contains additional information about
@@ -61,10 +80,26 @@ func newMessage(severity Severity, text string) *Message {
 		Severity:   severity,
 		Text:       text,
 		StatusCode: http.StatusInternalServerError,
+		Location:   callerLocation(3),
 	}
 	return m
 }
 
+// callerLocation returns the file, line and function of newMessage's
+// caller's caller - that is, with skip 3, the Debug/Info/Warn/Error call
+// site, not newMessage or the package function it called.
+func callerLocation(skip int) SourceLocation {
+	pc, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return SourceLocation{}
+	}
+	loc := SourceLocation{File: file, Line: line}
+	if fn := runtime.FuncForPC(pc); fn != nil {
+		loc.Function = fn.Name()
+	}
+	return loc
+}
+
 func (m *Message) applyOpt(opt Option) *Message {
 	opt(m)
 	return m
@@ -1,21 +1,36 @@
 package log
 
 import (
+	"errors"
 	"net/http"
+	"runtime"
 
 	"golang.org/x/net/context"
 )
 
+// stackTracer matches errs.StackTracer structurally, so that this package
+// can pick up a captured call stack without depending on the errs package.
+type stackTracer interface {
+	StackTrace() []runtime.Frame
+}
+
 // An Option is a function option that can be applied when logging a message.
 // See the example for how they are used. Options is based on Dave Cheney's article
 // "Functional options for friendly APIs" (http://goo.gl/l2KaW3)
 // that can be applied to a Message.
 type Option func(*Message)
 
-// WithError sets the error associated with the log message.
+// WithError sets the error associated with the log message. If err (or any
+// error it wraps) implements StackTrace() []runtime.Frame, such as an error
+// created by errs.New or errs.Wrap, the captured stack is attached to the
+// message too.
 func WithError(err error) Option {
 	return func(m *Message) {
 		m.Err = err
+		var st stackTracer
+		if errors.As(err, &st) {
+			m.Stack = st.StackTrace()
+		}
 	}
 }
 
@@ -0,0 +1,63 @@
+package httpctx
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"sp.com.au/exp/log"
+)
+
+// LogContext returns middleware that attaches a *log.HTTPRequest,
+// populated from r, to the request's context under log.HTTPRequestKey.
+// Any log call made while handling the request - log.Info("...",
+// log.WithContext(ctx)), for example - then carries method, URL, remote
+// address and user agent, so a formatter such as the one installed by
+// log.UseJSONFormatter can correlate every message logged for a request
+// with the request itself.
+//
+// Status and latency are not known until the wrapped Handler returns, so
+// they start zero (and are omitted from JSON output, see
+// log.UseJSONFormatter). Put LogContext ahead of AccessLog in the stack,
+// and AccessLog will fill them in on the same *log.HTTPRequest once the
+// request completes, so any later log call - including AccessLog's own -
+// sees the final values too.
+func LogContext() func(Handler) Handler {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			req := &log.HTTPRequest{
+				Method:    r.Method,
+				URL:       r.URL.String(),
+				RemoteIP:  remoteIP(r.RemoteAddr),
+				UserAgent: r.UserAgent(),
+			}
+			ctx = context.WithValue(ctx, httpRequestContextKey{}, req)
+			ctx = log.NewContext(ctx, log.HTTPRequestKey, req)
+			return h.ServeHTTPContext(ctx, w, r)
+		})
+	}
+}
+
+// httpRequestContextKey is the context key HTTPRequestFromContext looks
+// up, distinct from the key log.NewContext uses so that retrieving the
+// *log.HTTPRequest later (to fill in Status and Latency, say) does not
+// depend on log's context chain implementation.
+type httpRequestContextKey struct{}
+
+// HTTPRequestFromContext returns the *log.HTTPRequest attached by
+// LogContext, if any, so that other middleware (or h itself) can record
+// the eventual status and latency on it once they are known.
+func HTTPRequestFromContext(ctx context.Context) (*log.HTTPRequest, bool) {
+	req, ok := ctx.Value(httpRequestContextKey{}).(*log.HTTPRequest)
+	return req, ok
+}
+
+// remoteIP strips the port from addr (as found in a http.Request's
+// RemoteAddr field), falling back to addr unchanged if it has none.
+func remoteIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
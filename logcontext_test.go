@@ -0,0 +1,51 @@
+package httpctx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sp.com.au/exp/log"
+)
+
+func TestLogContextAttachesHTTPRequest(t *testing.T) {
+	var gotFromContext *log.HTTPRequest
+	h := HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		req, ok := HTTPRequestFromContext(ctx)
+		if !ok {
+			t.Fatal("expected an *log.HTTPRequest in the context")
+		}
+		gotFromContext = req
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/widgets?sort=name", nil)
+	r.RemoteAddr = "203.0.113.1:54321"
+	r.Header.Set("User-Agent", "test-agent")
+	w := httptest.NewRecorder()
+
+	if err := LogContext()(h).ServeHTTPContext(context.Background(), w, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotFromContext.Method != http.MethodGet {
+		t.Errorf("got method %q, want %q", gotFromContext.Method, http.MethodGet)
+	}
+	if gotFromContext.RemoteIP != "203.0.113.1" {
+		t.Errorf("got remote IP %q, want %q", gotFromContext.RemoteIP, "203.0.113.1")
+	}
+	if gotFromContext.UserAgent != "test-agent" {
+		t.Errorf("got user agent %q, want %q", gotFromContext.UserAgent, "test-agent")
+	}
+	if gotFromContext.Status != 0 || gotFromContext.Latency != 0 {
+		t.Errorf("expected Status and Latency to still be zero inside the handler, got %+v", gotFromContext)
+	}
+}
+
+func TestLogContextMissingWithoutMiddleware(t *testing.T) {
+	if _, ok := HTTPRequestFromContext(context.Background()); ok {
+		t.Error("expected no *log.HTTPRequest without LogContext middleware")
+	}
+}
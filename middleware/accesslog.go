@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	httpctx "sp.com.au/exp"
+	"sp.com.au/exp/errs"
+	"sp.com.au/exp/log"
+)
+
+// AccessLogRecord describes one completed HTTP request, as built by
+// AccessLog and passed to its sink.
+type AccessLogRecord struct {
+	Ctx context.Context
+
+	Start    time.Time
+	Duration time.Duration
+
+	RemoteAddr string
+	Proto      string
+	TLS        bool
+	Host       string
+	Method     string
+	RequestURI string
+	Referer    string
+	UserAgent  string
+
+	Status int
+	Bytes  int
+	Err    error
+}
+
+// accessLogOptions holds the configuration built up by AccessLogOption
+// functions passed to AccessLog.
+type accessLogOptions struct {
+	sink func(*AccessLogRecord)
+}
+
+// AccessLogOption configures AccessLog. See WithSink.
+type AccessLogOption func(*accessLogOptions)
+
+// WithSink replaces the default sink (which logs through the log
+// package, see logAccessRecord) with sink, for example to emit records as
+// JSON, or forward them to an analytics pipeline.
+func WithSink(sink func(*AccessLogRecord)) AccessLogOption {
+	return func(o *accessLogOptions) {
+		o.sink = sink
+	}
+}
+
+// AccessLog returns middleware that builds an AccessLogRecord for every
+// completed request and passes it to a sink (logAccessRecord, logging
+// through the log package, unless overridden with WithSink).
+//
+// The status and byte count come from an instrumented ResponseWriter
+// passed down to h, so they reflect what was actually written to the
+// client for a successful request. If h returns an error instead of
+// writing a response itself, the recorded status is derived from the
+// error the same way this package's own error handling would (see
+// errs.Error), since the actual response is written later, by the
+// Handle/Stack that this middleware is used from.
+//
+// The wrapped ResponseWriter passed to h still implements http.Flusher,
+// http.Hijacker and http.CloseNotifier whenever the underlying
+// ResponseWriter does (see instrument), so streaming responses and
+// websocket upgrades are unaffected by this middleware.
+func AccessLog(opts ...AccessLogOption) func(httpctx.Handler) httpctx.Handler {
+	o := accessLogOptions{sink: logAccessRecord}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(h httpctx.Handler) httpctx.Handler {
+		return httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			start := time.Now()
+			iw, stats := instrument(w)
+
+			err := h.ServeHTTPContext(ctx, iw, r)
+
+			status := stats.Status()
+			if err != nil && !stats.HeaderWritten() {
+				status = statusCodeFor(err)
+			}
+			duration := time.Since(start)
+
+			if req, ok := httpctx.HTTPRequestFromContext(ctx); ok {
+				req.Status = status
+				req.Latency = duration
+			}
+
+			o.sink(&AccessLogRecord{
+				Ctx:        ctx,
+				Start:      start,
+				Duration:   duration,
+				RemoteAddr: r.RemoteAddr,
+				Proto:      r.Proto,
+				TLS:        r.TLS != nil,
+				Host:       r.Host,
+				Method:     r.Method,
+				RequestURI: r.RequestURI,
+				Referer:    r.Referer(),
+				UserAgent:  r.UserAgent(),
+				Status:     status,
+				Bytes:      stats.BytesWritten(),
+				Err:        err,
+			})
+
+			return err
+		})
+	}
+}
+
+// logAccessRecord is the default AccessLog sink: it logs one structured
+// message per request, via the log package, carrying the same fields
+// AccessLog has always logged plus the additional detail captured in
+// AccessLogRecord.
+func logAccessRecord(rec *AccessLogRecord) {
+	opts := []log.Option{
+		log.WithValue("method", rec.Method),
+		log.WithValue("path", rec.RequestURI),
+		log.WithValue("status", rec.Status),
+		log.WithValue("bytes", rec.Bytes),
+		log.WithValue("duration", rec.Duration),
+		log.WithValue("remote_addr", rec.RemoteAddr),
+		log.WithValue("proto", rec.Proto),
+		log.WithValue("host", rec.Host),
+	}
+	if rec.TLS {
+		opts = append(opts, log.WithValue("tls", rec.TLS))
+	}
+	if rec.Referer != "" {
+		opts = append(opts, log.WithValue("referer", rec.Referer))
+	}
+	if rec.UserAgent != "" {
+		opts = append(opts, log.WithValue("user_agent", rec.UserAgent))
+	}
+	if rec.Ctx != nil {
+		opts = append(opts, log.WithContext(rec.Ctx))
+	}
+	if rec.Err != nil {
+		opts = append(opts, log.WithError(rec.Err))
+		if code := errs.Code(rec.Err); code != "" {
+			opts = append(opts, log.WithValue("code", code))
+		}
+	}
+	log.Info("http.access", opts...)
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	httpctx "sp.com.au/exp"
+)
+
+// Registry receives per-request counts from the Metrics middleware. It is
+// deliberately minimal, so that any metrics backend (an in-memory counter
+// map, Prometheus, a varz-style exporter, ...) can be adapted to it
+// without this package needing to depend on one.
+type Registry interface {
+	// IncRequestCount increments the counter for one completed request,
+	// identified by its HTTP method, route (r.URL.Path) and final
+	// status code.
+	IncRequestCount(method, route string, status int)
+}
+
+// HistogramRegistry is a Registry that can also record how long each
+// request took. Metrics calls ObserveLatency after IncRequestCount when
+// registry implements this interface; see varz.Registry for one that
+// does.
+type HistogramRegistry interface {
+	Registry
+
+	// ObserveLatency records d as the duration of one completed
+	// request, identified the same way as IncRequestCount.
+	ObserveLatency(method, route string, status int, d time.Duration)
+}
+
+// Metrics returns middleware that increments a per-method/route/status
+// counter in registry for every request handled by h, and, if registry
+// also implements HistogramRegistry, records the request's duration. The
+// status is derived the same way as in AccessLog: from the instrumented
+// ResponseWriter if h wrote a response itself, or from the returned
+// error otherwise.
+func Metrics(registry Registry) func(httpctx.Handler) httpctx.Handler {
+	return func(h httpctx.Handler) httpctx.Handler {
+		return httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			start := time.Now()
+			iw, stats := instrument(w)
+
+			err := h.ServeHTTPContext(ctx, iw, r)
+
+			status := stats.Status()
+			if err != nil && !stats.HeaderWritten() {
+				status = statusCodeFor(err)
+			}
+			registry.IncRequestCount(r.Method, r.URL.Path, status)
+			if hr, ok := registry.(HistogramRegistry); ok {
+				hr.ObserveLatency(r.Method, r.URL.Path, status, time.Since(start))
+			}
+
+			return err
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httpctx "sp.com.au/exp"
+	"sp.com.au/exp/errs"
+	"sp.com.au/exp/log"
+	"sp.com.au/exp/middleware"
+)
+
+func withCapturedLog(t *testing.T, f func()) *log.Message {
+	t.Helper()
+	prevPrint := log.Print
+	defer func() { log.Print = prevPrint }()
+
+	var captured *log.Message
+	log.Print = func(m *log.Message) { captured = m }
+
+	f()
+	return captured
+}
+
+func TestAccessLogSuccessfulRequest(t *testing.T) {
+	h := httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("ok"))
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	w := httptest.NewRecorder()
+
+	msg := withCapturedLog(t, func() {
+		err := middleware.AccessLog()(h).ServeHTTPContext(context.Background(), w, r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	if msg == nil {
+		t.Fatal("expected a log message to be captured")
+	}
+	params := map[string]interface{}{}
+	for _, p := range msg.Parameters {
+		params[p.Name] = p.Value
+	}
+	if params["status"] != http.StatusCreated {
+		t.Errorf("got status %v, want %d", params["status"], http.StatusCreated)
+	}
+	if params["bytes"] != 2 {
+		t.Errorf("got bytes %v, want 2", params["bytes"])
+	}
+	if params["path"] != "/widgets" {
+		t.Errorf("got path %v, want /widgets", params["path"])
+	}
+}
+
+func TestAccessLogErrorFromHandler(t *testing.T) {
+	wantErr := errs.BadRequest("nope")
+	h := httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return wantErr
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/widgets", nil)
+	w := httptest.NewRecorder()
+
+	msg := withCapturedLog(t, func() {
+		err := middleware.AccessLog()(h).ServeHTTPContext(context.Background(), w, r)
+		if err != wantErr {
+			t.Fatalf("got error %v, want %v", err, wantErr)
+		}
+	})
+
+	if msg == nil {
+		t.Fatal("expected a log message to be captured")
+	}
+	params := map[string]interface{}{}
+	for _, p := range msg.Parameters {
+		params[p.Name] = p.Value
+	}
+	if params["status"] != http.StatusBadRequest {
+		t.Errorf("got status %v, want %d", params["status"], http.StatusBadRequest)
+	}
+	if msg.Err != wantErr {
+		t.Errorf("got logged error %v, want %v", msg.Err, wantErr)
+	}
+}
+
+func TestAccessLogWithSinkCapturesRichRecord(t *testing.T) {
+	h := httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/widgets?sort=name", nil)
+	r.Header.Set("Referer", "https://example.com/")
+	r.Header.Set("User-Agent", "test-agent")
+	r.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+
+	var got *middleware.AccessLogRecord
+	mw := middleware.AccessLog(middleware.WithSink(func(rec *middleware.AccessLogRecord) {
+		got = rec
+	}))
+	if err := mw(h).ServeHTTPContext(context.Background(), w, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got == nil {
+		t.Fatal("expected the sink to be called")
+	}
+	if got.Method != http.MethodGet || got.RequestURI != "/widgets?sort=name" {
+		t.Errorf("got method %q requestURI %q, want %q /widgets?sort=name", got.Method, got.RequestURI, http.MethodGet)
+	}
+	if got.Status != http.StatusOK || got.Bytes != 2 {
+		t.Errorf("got status %d bytes %d, want %d 2", got.Status, got.Bytes, http.StatusOK)
+	}
+	if got.RemoteAddr != r.RemoteAddr || got.Referer != "https://example.com/" || got.UserAgent != "test-agent" {
+		t.Errorf("got remoteAddr %q referer %q userAgent %q, unexpected", got.RemoteAddr, got.Referer, got.UserAgent)
+	}
+	if got.TLS {
+		t.Error("got TLS true for a plain HTTP request")
+	}
+}
+
+type fakeRegistry struct {
+	method, route string
+	status        int
+	calls         int
+}
+
+func (f *fakeRegistry) IncRequestCount(method, route string, status int) {
+	f.method, f.route, f.status = method, route, status
+	f.calls++
+}
+
+func TestMetricsIncrementsCounterOnSuccess(t *testing.T) {
+	h := httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+
+	reg := &fakeRegistry{}
+	r := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	w := httptest.NewRecorder()
+
+	if err := middleware.Metrics(reg)(h).ServeHTTPContext(context.Background(), w, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if reg.calls != 1 {
+		t.Fatalf("got %d calls to IncRequestCount, want 1", reg.calls)
+	}
+	if reg.method != http.MethodGet || reg.route != "/widgets" || reg.status != http.StatusOK {
+		t.Errorf("got (%s, %s, %d), want (%s, /widgets, %d)",
+			reg.method, reg.route, reg.status, http.MethodGet, http.StatusOK)
+	}
+}
+
+func TestMetricsIncrementsCounterOnError(t *testing.T) {
+	h := httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return errs.Forbidden("no")
+	})
+
+	reg := &fakeRegistry{}
+	r := httptest.NewRequest(http.MethodGet, "/widgets", nil)
+	w := httptest.NewRecorder()
+
+	err := middleware.Metrics(reg)(h).ServeHTTPContext(context.Background(), w, r)
+	if err == nil {
+		t.Fatal("expected the error to propagate")
+	}
+	if reg.status != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", reg.status, http.StatusForbidden)
+	}
+}
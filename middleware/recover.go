@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+
+	httpctx "sp.com.au/exp"
+	"sp.com.au/exp/errs"
+)
+
+// recoverOptions holds the configuration built up by RecoverOption
+// functions passed to Recover.
+type recoverOptions struct {
+	onPanic          func(ctx context.Context, r *http.Request, value interface{})
+	passthroughAbort bool
+}
+
+// RecoverOption configures Recover. See WithPanicHandler and
+// WithAbortHandlerPassthrough.
+type RecoverOption func(*recoverOptions)
+
+// WithPanicHandler sets a callback that is invoked with the recovered
+// panic value before Recover converts it into an error, for example to
+// alert an on-call engineer. It runs even when the panic value is
+// http.ErrAbortHandler.
+func WithPanicHandler(f func(ctx context.Context, r *http.Request, value interface{})) RecoverOption {
+	return func(o *recoverOptions) {
+		o.onPanic = f
+	}
+}
+
+// WithAbortHandlerPassthrough controls what Recover does when the
+// recovered value is http.ErrAbortHandler. net/http treats a panic with
+// that value as a deliberate, silent abort of the response (see the
+// http.ErrAbortHandler documentation); by default (passthrough=true)
+// Recover preserves that behaviour by re-panicking with it, rather than
+// converting it into an errs.ServerError, so that the surrounding
+// net/http server handles it exactly as it would without this
+// middleware. Passing false converts it into an error like any other
+// panic.
+func WithAbortHandlerPassthrough(passthrough bool) RecoverOption {
+	return func(o *recoverOptions) {
+		o.passthroughAbort = passthrough
+	}
+}
+
+// Recover returns middleware that recovers from a panic in the wrapped
+// handler and converts it into an error with a HTTP status code of 500,
+// so that it flows through the package's normal error handling (see
+// sendError) instead of leaking net/http's own default 500 response. In
+// development (see env.IsDevelopment()), sendError includes the captured
+// stack trace in the response body, since the returned error wraps one
+// that implements errs.StackTracer; the original panic value is available to other
+// middleware, such as AccessLog, via PanicValue.
+func Recover(opts ...RecoverOption) func(httpctx.Handler) httpctx.Handler {
+	o := recoverOptions{passthroughAbort: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return func(h httpctx.Handler) httpctx.Handler {
+		return httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) (err error) {
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				}
+				if o.onPanic != nil {
+					o.onPanic(ctx, r, value)
+				}
+				if o.passthroughAbort && value == http.ErrAbortHandler {
+					panic(value)
+				}
+				err = errs.Visible("internal server error", http.StatusInternalServerError,
+					&panicError{value: value, pcs: callers()})
+			}()
+			return h.ServeHTTPContext(ctx, w, r)
+		})
+	}
+}
+
+// panicError wraps the value recovered from a panic, together with the
+// stack captured at the point of recovery. Its message is not returned
+// to the client directly: Recover wraps it in errs.Visible, so that only
+// the generic "internal server error" message reaches the client, while
+// this value (and its stack) stay available to logging middleware via
+// PanicValue and errs.StackTracer.
+type panicError struct {
+	value interface{}
+	pcs   []uintptr
+}
+
+// Error implements the error interface.
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// StackTrace implements errs.StackTracer, resolving the captured program
+// counters into frames on demand.
+func (e *panicError) StackTrace() []runtime.Frame {
+	if len(e.pcs) == 0 {
+		return nil
+	}
+	frames := runtime.CallersFrames(e.pcs)
+	var stack []runtime.Frame
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, frame)
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// PanicValue returns the value passed to panic that err, or an error it
+// wraps, was recovered from by Recover, and whether one was found.
+func PanicValue(err error) (interface{}, bool) {
+	var pe *panicError
+	if errors.As(err, &pe) {
+		return pe.value, true
+	}
+	return nil, false
+}
+
+// callers captures the call stack at the point it is called, skipping
+// its own frame, for attaching to panicError.
+func callers() []uintptr {
+	const maxDepth = 32
+	var pcs [maxDepth]uintptr
+	n := runtime.Callers(2, pcs[:])
+	return pcs[:n]
+}
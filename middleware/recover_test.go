@@ -0,0 +1,121 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httpctx "sp.com.au/exp"
+	"sp.com.au/exp/errs"
+	"sp.com.au/exp/middleware"
+)
+
+func TestRecoverConvertsPanicToError(t *testing.T) {
+	h := httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		panic("boom")
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	err := middleware.Recover()(h).ServeHTTPContext(context.Background(), w, r)
+	if err == nil {
+		t.Fatal("expected Recover to return an error")
+	}
+
+	var e errs.Error
+	if !asErrsError(err, &e) {
+		t.Fatalf("expected an errs.Error, got %T: %v", err, err)
+	}
+	if e.StatusCode() != http.StatusInternalServerError {
+		t.Errorf("got status %d, want %d", e.StatusCode(), http.StatusInternalServerError)
+	}
+
+	value, ok := middleware.PanicValue(err)
+	if !ok {
+		t.Fatal("expected PanicValue to find the recovered value")
+	}
+	if value != "boom" {
+		t.Errorf("got panic value %v, want %q", value, "boom")
+	}
+}
+
+func TestRecoverDoesNotInterfereWithSuccess(t *testing.T) {
+	h := httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	if err := middleware.Recover()(h).ServeHTTPContext(context.Background(), w, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRecoverPropagatesErrAbortHandlerByDefault(t *testing.T) {
+	h := httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		panic(http.ErrAbortHandler)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	defer func() {
+		if recover() != http.ErrAbortHandler {
+			t.Error("expected http.ErrAbortHandler to be re-panicked")
+		}
+	}()
+	middleware.Recover()(h).ServeHTTPContext(context.Background(), w, r)
+	t.Error("expected a panic")
+}
+
+func TestRecoverWithAbortHandlerPassthroughDisabled(t *testing.T) {
+	h := httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		panic(http.ErrAbortHandler)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	err := middleware.Recover(middleware.WithAbortHandlerPassthrough(false))(h).
+		ServeHTTPContext(context.Background(), w, r)
+	if err == nil {
+		t.Fatal("expected an error instead of a re-panic")
+	}
+}
+
+func TestRecoverCallsPanicHandler(t *testing.T) {
+	h := httpctx.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		panic("boom")
+	})
+
+	var handled interface{}
+	recoverMiddleware := middleware.Recover(middleware.WithPanicHandler(
+		func(ctx context.Context, r *http.Request, value interface{}) {
+			handled = value
+		}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	recoverMiddleware(h).ServeHTTPContext(context.Background(), w, r)
+
+	if handled != "boom" {
+		t.Errorf("got panic handler value %v, want %q", handled, "boom")
+	}
+}
+
+// asErrsError reports whether err implements errs.Error, and if so
+// assigns it to *out.
+func asErrsError(err error, out *errs.Error) bool {
+	if e, ok := err.(errs.Error); ok {
+		*out = e
+		return true
+	}
+	return false
+}
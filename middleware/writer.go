@@ -0,0 +1,171 @@
+// Package middleware provides standard httpctx.Handler middleware:
+// access logging and request metrics.
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// instrumentedWriter wraps a http.ResponseWriter, recording the status
+// code eventually written to it (defaulting to 200 if WriteHeader is
+// never called) and the number of bytes written.
+type instrumentedWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter, recording the first status
+// code it is called with.
+func (w *instrumentedWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter, counting the bytes written.
+func (w *instrumentedWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Status returns the status code written to the client, or 200 if
+// WriteHeader was never called.
+func (w *instrumentedWriter) Status() int {
+	if !w.wroteHeader {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// BytesWritten returns the number of response body bytes written so far.
+func (w *instrumentedWriter) BytesWritten() int {
+	return w.bytes
+}
+
+// HeaderWritten reports whether WriteHeader or Write has been called yet.
+func (w *instrumentedWriter) HeaderWritten() bool {
+	return w.wroteHeader
+}
+
+// instrument wraps w for status/byte-count tracking, returning the
+// wrapper to pass down the handler chain and the *instrumentedWriter to
+// read the results back from once the request is complete.
+//
+// The concrete type returned is chosen, via type assertions against w, to
+// forward Flush, Hijack and CloseNotify only when w itself supports them.
+// This matters because httpctx's Context middleware detects
+// http.CloseNotifier with its own type assertion when setting up the
+// request context; a wrapper that always implemented CloseNotify, even
+// when the writer underneath does not support it, would make that
+// detection lie.
+func instrument(w http.ResponseWriter) (http.ResponseWriter, *instrumentedWriter) {
+	iw := &instrumentedWriter{ResponseWriter: w}
+
+	_, isFlusher := w.(http.Flusher)
+	_, isHijacker := w.(http.Hijacker)
+	_, isCloseNotifier := w.(http.CloseNotifier)
+
+	switch {
+	case isFlusher && isHijacker && isCloseNotifier:
+		return &flusherHijackerCloseNotifier{iw}, iw
+	case isFlusher && isHijacker:
+		return &flusherHijacker{iw}, iw
+	case isFlusher && isCloseNotifier:
+		return &flusherCloseNotifier{iw}, iw
+	case isHijacker && isCloseNotifier:
+		return &hijackerCloseNotifier{iw}, iw
+	case isFlusher:
+		return &flusher{iw}, iw
+	case isHijacker:
+		return &hijacker{iw}, iw
+	case isCloseNotifier:
+		return &closeNotifier{iw}, iw
+	default:
+		return iw, iw
+	}
+}
+
+type flusher struct{ *instrumentedWriter }
+
+func (w *flusher) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+type hijacker struct{ *instrumentedWriter }
+
+func (w *hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type closeNotifier struct{ *instrumentedWriter }
+
+func (w *closeNotifier) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+type flusherHijacker struct{ *instrumentedWriter }
+
+func (w *flusherHijacker) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *flusherHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type flusherCloseNotifier struct{ *instrumentedWriter }
+
+func (w *flusherCloseNotifier) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *flusherCloseNotifier) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+type hijackerCloseNotifier struct{ *instrumentedWriter }
+
+func (w *hijackerCloseNotifier) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+func (w *hijackerCloseNotifier) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+type flusherHijackerCloseNotifier struct{ *instrumentedWriter }
+
+func (w *flusherHijackerCloseNotifier) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *flusherHijackerCloseNotifier) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+func (w *flusherHijackerCloseNotifier) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// statusCodeFor returns the status code that httpctx's default error
+// handling would use for err: the code from its StatusCode() method, if
+// it has one and it is non-zero, or 500 (Internal Server Error)
+// otherwise. It lets middleware that observes err, without itself
+// writing the response, still report the status the client will
+// actually see.
+func statusCodeFor(err error) int {
+	if e, ok := err.(interface{ StatusCode() int }); ok && e.StatusCode() != 0 {
+		return e.StatusCode()
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+type flushingWriter struct {
+	http.ResponseWriter
+	flushed bool
+}
+
+func (w *flushingWriter) Flush() { w.flushed = true }
+
+func TestInstrumentTracksStatusAndBytes(t *testing.T) {
+	rr := httptest.NewRecorder()
+	iw, stats := instrument(rr)
+
+	if stats.HeaderWritten() {
+		t.Fatal("HeaderWritten should be false before any write")
+	}
+	if got := stats.Status(); got != http.StatusOK {
+		t.Errorf("got default status %d, want %d", got, http.StatusOK)
+	}
+
+	iw.WriteHeader(http.StatusAccepted)
+	n, err := iw.Write([]byte("hello"))
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if n != 5 {
+		t.Errorf("got %d bytes written, want 5", n)
+	}
+
+	if !stats.HeaderWritten() {
+		t.Error("HeaderWritten should be true after WriteHeader")
+	}
+	if got := stats.Status(); got != http.StatusAccepted {
+		t.Errorf("got status %d, want %d", got, http.StatusAccepted)
+	}
+	if got := stats.BytesWritten(); got != 5 {
+		t.Errorf("got %d bytes written, want 5", got)
+	}
+}
+
+func TestInstrumentDoesNotAddUnsupportedInterfaces(t *testing.T) {
+	iw, _ := instrument(&plainWriter{httptest.NewRecorder()})
+
+	if _, ok := iw.(http.Flusher); ok {
+		t.Error("wrapper should not implement http.Flusher when the underlying writer does not")
+	}
+	if _, ok := iw.(http.Hijacker); ok {
+		t.Error("wrapper should not implement http.Hijacker when the underlying writer does not")
+	}
+	if _, ok := iw.(http.CloseNotifier); ok {
+		t.Error("wrapper should not implement http.CloseNotifier when the underlying writer does not")
+	}
+}
+
+func TestInstrumentForwardsFlush(t *testing.T) {
+	underlying := &flushingWriter{ResponseWriter: httptest.NewRecorder()}
+	iw, _ := instrument(underlying)
+
+	flusher, ok := iw.(http.Flusher)
+	if !ok {
+		t.Fatal("wrapper should implement http.Flusher when the underlying writer does")
+	}
+	flusher.Flush()
+	if !underlying.flushed {
+		t.Error("Flush was not forwarded to the underlying writer")
+	}
+}
+
+// closeNotifyingWriter also implements http.Hijacker, to exercise the
+// combined flusherHijackerCloseNotifier wrapper.
+type closeNotifyingWriter struct {
+	http.ResponseWriter
+	closeChan chan bool
+}
+
+func (w *closeNotifyingWriter) CloseNotify() <-chan bool { return w.closeChan }
+
+func (w *closeNotifyingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func TestInstrumentForwardsCloseNotifyAndHijack(t *testing.T) {
+	underlying := &closeNotifyingWriter{
+		ResponseWriter: httptest.NewRecorder(),
+		closeChan:      make(chan bool, 1),
+	}
+	iw, _ := instrument(underlying)
+
+	cn, ok := iw.(http.CloseNotifier)
+	if !ok {
+		t.Fatal("wrapper should implement http.CloseNotifier when the underlying writer does")
+	}
+	underlying.closeChan <- true
+	select {
+	case <-cn.CloseNotify():
+	default:
+		t.Error("CloseNotify was not forwarded to the underlying writer")
+	}
+
+	if _, ok := iw.(http.Hijacker); !ok {
+		t.Error("wrapper should implement http.Hijacker when the underlying writer does")
+	}
+}
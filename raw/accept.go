@@ -0,0 +1,89 @@
+package raw
+
+import (
+	"strconv"
+	"strings"
+)
+
+// encodingPref is one coding/qvalue pair parsed from an Accept-Encoding
+// header.
+type encodingPref struct {
+	coding string
+	q      float64
+}
+
+// parseAcceptEncoding parses header per RFC 7231 §5.3.4 into the codings
+// it mentions, along with their q-values. A missing or blank header
+// parses to a single "*"; q=1 entry, matching the rule that "if no
+// Accept-Encoding field is present... any content-coding is acceptable".
+func parseAcceptEncoding(header string) []encodingPref {
+	if strings.TrimSpace(header) == "" {
+		return []encodingPref{{"*", 1}}
+	}
+
+	var prefs []encodingPref
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		coding := part
+		q := 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			coding = strings.TrimSpace(part[:i])
+			for _, param := range strings.Split(part[i+1:], ";") {
+				name, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(name), "q") {
+					continue
+				}
+				if v, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if coding == "" {
+			continue
+		}
+		prefs = append(prefs, encodingPref{strings.ToLower(coding), q})
+	}
+	return prefs
+}
+
+// acceptableEncoding picks the best coding from candidates (given in
+// server preference order) that header, parsed per RFC 7231 §5.3.4,
+// permits: one with neither an explicit "q=0" entry nor a "*;q=0"
+// blanket rejection. Per §5.3.4, "identity" is acceptable with q=1 by
+// default when header does not mention it and has no "*" entry; every
+// other coding header does not mention is unacceptable unless "*"
+// covers it. It returns ok=false if none of candidates is acceptable.
+func acceptableEncoding(header string, candidates []string) (coding string, ok bool) {
+	prefs := parseAcceptEncoding(header)
+
+	qFor := func(coding string) float64 {
+		star, starSeen := 0.0, false
+		for _, p := range prefs {
+			if p.coding == coding {
+				return p.q
+			}
+			if p.coding == "*" {
+				star, starSeen = p.q, true
+			}
+		}
+		if starSeen {
+			return star
+		}
+		if coding == ceIdentity {
+			return 1
+		}
+		return 0
+	}
+
+	bestQ := 0.0
+	for _, c := range candidates {
+		if q := qFor(c); q > 0 && q > bestQ {
+			coding, bestQ, ok = c, q, true
+		}
+	}
+	return coding, ok
+}
@@ -0,0 +1,44 @@
+package raw
+
+import "testing"
+
+func TestAcceptableEncoding(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		candidates []string
+		want       string
+		ok         bool
+	}{
+		{"no header accepts anything", "", []string{"gzip", "identity"}, "gzip", true},
+		{"exact match", "gzip", []string{"gzip", "identity"}, "gzip", true},
+		{"q=0 rejects", "gzip;q=0, identity", []string{"gzip", "identity"}, "identity", true},
+		{"star q=0 rejects unlisted", "gzip, *;q=0", []string{"gzip", "deflate", "identity"}, "gzip", true},
+		{"star q=0 rejects everything unlisted", "*;q=0", []string{"gzip", "identity"}, "", false},
+		{"higher q wins", "gzip;q=0.5, br;q=1.0", []string{"gzip", "br", "identity"}, "br", true},
+		{"tie keeps candidate order", "gzip, br", []string{"gzip", "br", "identity"}, "gzip", true},
+		{"only identity defaults to acceptable when unmentioned", "deflate", []string{"gzip", "identity"}, "identity", true},
+		{"unmentioned non-identity coding is not acceptable", "deflate", []string{"gzip"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := acceptableEncoding(tt.header, tt.candidates)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("acceptableEncoding(%q, %v) = %q, %v; want %q, %v", tt.header, tt.candidates, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseAcceptEncodingQValues(t *testing.T) {
+	prefs := parseAcceptEncoding("gzip;q=0.8, br;q=1.0, deflate")
+	want := map[string]float64{"gzip": 0.8, "br": 1.0, "deflate": 1.0}
+	if len(prefs) != len(want) {
+		t.Fatalf("got %d prefs, want %d: %+v", len(prefs), len(want), prefs)
+	}
+	for _, p := range prefs {
+		if q, ok := want[p.coding]; !ok || q != p.q {
+			t.Errorf("got %s;q=%v, want q=%v", p.coding, p.q, want[p.coding])
+		}
+	}
+}
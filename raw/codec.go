@@ -0,0 +1,74 @@
+package raw
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+)
+
+// Codec adapts a wire format (as used in the Content-Type header) to
+// Go's encoding/marshal conventions, so a Data can pick its wire format
+// based on ContentType instead of being hardwired to JSON. See
+// RegisterCodec.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte, v interface{}) error
+	ContentType() string
+}
+
+// codecs holds every wire format UnmarshalTo, MarshalFrom and
+// MarshalFromAs know how to produce and consume, keyed by ContentType.
+// application/json and application/xml are registered by default.
+var codecs = map[string]Codec{
+	jsonCodec{}.ContentType(): jsonCodec{},
+	xmlCodec{}.ContentType():  xmlCodec{},
+}
+
+// RegisterCodec makes codec available, under its own ContentType(), to
+// UnmarshalTo, MarshalFrom and MarshalFromAs, in addition to the JSON
+// and XML codecs this package already knows. Call it from an init
+// function, for example to add protobuf or msgpack support by wrapping
+// a third-party package:
+//
+//	raw.RegisterCodec(protobufCodec{})
+//
+// KNOWN GAP: application/x-protobuf and application/msgpack were
+// requested alongside this registry but are not implemented here.
+// Unlike gzip/deflate, a correct protobuf codec needs the caller's
+// generated message types (proto.Message, reflection over field tags)
+// to marshal against, not just an algorithm this package could
+// self-host; msgpack is more tractable but still needs a real encoder
+// this snapshot has no dependency mechanism to pull in. Both are left
+// as a genuine TODO for whoever next touches this package with a
+// dependency story, via RegisterCodec.
+func RegisterCodec(codec Codec) {
+	codecs[codec.ContentType()] = codec
+}
+
+// lookupCodec returns the Codec registered for contentType, falling
+// back to JSON when contentType is empty or unrecognised, to preserve
+// this package's original JSON-only behaviour. Any parameters on
+// contentType (the "; charset=utf-8" real clients routinely send
+// alongside the likes of application/xml) are stripped before the
+// registry lookup, so they do not defeat the match.
+func lookupCodec(contentType string) Codec {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	if codec, ok := codecs[contentType]; ok {
+		return codec
+	}
+	return jsonCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)   { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(b []byte, v interface{}) error { return json.Unmarshal(b, v) }
+func (jsonCodec) ContentType() string                     { return "application/json" }
+
+type xmlCodec struct{}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error)   { return xml.Marshal(v) }
+func (xmlCodec) Unmarshal(b []byte, v interface{}) error { return xml.Unmarshal(b, v) }
+func (xmlCodec) ContentType() string                     { return "application/xml" }
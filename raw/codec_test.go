@@ -0,0 +1,100 @@
+package raw
+
+import "testing"
+
+type codecTestPayload struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func TestMarshalFromAndUnmarshalToRoundTripJSON(t *testing.T) {
+	var data Data
+	in := codecTestPayload{Name: "alice"}
+	if err := data.MarshalFrom(in); err != nil {
+		t.Fatalf("MarshalFrom: %v", err)
+	}
+	if data.ContentType != "application/json" {
+		t.Errorf("got ContentType %q, want application/json", data.ContentType)
+	}
+
+	var out codecTestPayload
+	if err := data.UnmarshalTo(&out); err != nil {
+		t.Fatalf("UnmarshalTo: %v", err)
+	}
+	if out != in {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestMarshalFromAsRoundTripsRegisteredContentType(t *testing.T) {
+	var data Data
+	in := codecTestPayload{Name: "bob"}
+	if err := data.MarshalFromAs(in, "application/xml"); err != nil {
+		t.Fatalf("MarshalFromAs: %v", err)
+	}
+	if data.ContentType != "application/xml" {
+		t.Errorf("got ContentType %q, want application/xml", data.ContentType)
+	}
+
+	var out codecTestPayload
+	if err := data.UnmarshalTo(&out); err != nil {
+		t.Fatalf("UnmarshalTo: %v", err)
+	}
+	if out != in {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestUnmarshalToFallsBackToJSONForUnknownContentType(t *testing.T) {
+	data := Data{Content: []byte(`{"name":"carol"}`), ContentType: "application/x-protobuf"}
+
+	var out codecTestPayload
+	if err := data.UnmarshalTo(&out); err != nil {
+		t.Fatalf("UnmarshalTo: %v", err)
+	}
+	if out.Name != "carol" {
+		t.Errorf("got %+v, want Name carol", out)
+	}
+}
+
+func TestUnmarshalToStripsContentTypeParameters(t *testing.T) {
+	data := Data{
+		Content:     []byte(`<codecTestPayload><name>erin</name></codecTestPayload>`),
+		ContentType: "application/xml; charset=utf-8",
+	}
+
+	var out codecTestPayload
+	if err := data.UnmarshalTo(&out); err != nil {
+		t.Fatalf("UnmarshalTo: %v", err)
+	}
+	if out.Name != "erin" {
+		t.Errorf("got %+v, want Name erin", out)
+	}
+}
+
+func TestRegisterCodecAddsContentTypeForMarshalFromAs(t *testing.T) {
+	const contentType = "application/x-test-codec"
+	RegisterCodec(testCodec{})
+	defer delete(codecs, contentType)
+
+	var data Data
+	if err := data.MarshalFromAs(codecTestPayload{Name: "dave"}, contentType); err != nil {
+		t.Fatalf("MarshalFromAs: %v", err)
+	}
+
+	var out codecTestPayload
+	if err := data.UnmarshalTo(&out); err != nil {
+		t.Fatalf("UnmarshalTo: %v", err)
+	}
+	if out.Name != "dave" {
+		t.Errorf("got %+v, want Name dave", out)
+	}
+}
+
+// testCodec is a trivial JSON-backed Codec registered under a made-up
+// content type, standing in for a third-party codec like protobuf or
+// msgpack, to exercise RegisterCodec without bundling one.
+type testCodec struct{}
+
+func (testCodec) Marshal(v interface{}) ([]byte, error)   { return jsonCodec{}.Marshal(v) }
+func (testCodec) Unmarshal(b []byte, v interface{}) error { return jsonCodec{}.Unmarshal(b, v) }
+func (testCodec) ContentType() string                     { return "application/x-test-codec" }
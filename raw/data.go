@@ -5,14 +5,11 @@ package raw
 
 import (
 	"bytes"
-	"compress/flate"
-	"compress/gzip"
-	"encoding/json"
+	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"golang.org/x/net/context"
 	"sp.com.au/exp/errs"
@@ -47,48 +44,24 @@ func (data *Data) IsCompressed() bool {
 	return data.ContentEncoding != ceIdentity
 }
 
-// ReadRequest reads the data from the request into the raw.Data.
-func (data *Data) ReadRequest(ctx context.Context, r *http.Request) error {
-	if cl := r.Header.Get("Content-Length"); cl != "" {
-		v, err := strconv.ParseInt(cl, 10, 64)
-		if err != nil || v < 0 {
-			return log.Warn("invalid content-length",
-				log.WithValue("content-length", cl),
-				log.WithContext(ctx),
-				log.WithStatusBadRequest())
-		}
-
-		if v >= int64(MaxLen) {
-			return log.Warn("max length excceeded",
-				log.WithContext(ctx),
-				log.WithStatusBadRequest(),
-				log.WithValue("MaxLen", MaxLen))
-		}
-
-		buf := make([]byte, v)
+// ReadRequest reads the data from the request into the raw.Data. r.Body
+// is wrapped in http.MaxBytesReader, bounded by MaxLen, so neither a
+// hostile Content-Length nor a client that simply keeps sending data can
+// pin more than MaxLen bytes of memory; w is required so MaxBytesReader
+// can flag the connection once the limit is hit. A request that exceeds
+// MaxLen comes back as a 413 Request Entity Too Large.
+//
+// To stream a body larger than MaxLen straight to its eventual
+// destination - a file, say - instead of buffering it into Content, use
+// ReadRequestTo with your own limit applied to r.Body.
+func (data *Data) ReadRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(MaxLen))
 
-		_, err = io.ReadFull(r.Body, buf)
-		if err != nil {
-			return log.Warn("cannot read content",
-				log.WithContext(ctx),
-				log.WithError(err),
-				log.WithStatusBadRequest())
-		}
-		data.Content = buf
-	} else {
-		reader := io.LimitReader(r.Body, int64(MaxLen))
-		content, err := ioutil.ReadAll(reader)
-		if err != nil {
-			return err
-		}
-		if len(content) >= MaxLen {
-			return log.Warn("max size exceeded",
-				log.WithContext(ctx),
-				log.WithStatusBadRequest(),
-				log.WithValue("MaxLen", MaxLen))
-		}
-		data.Content = content
+	var buf bytes.Buffer
+	if _, err := data.ReadRequestTo(ctx, r, &buf); err != nil {
+		return err
 	}
+	data.Content = buf.Bytes()
 
 	// The HTTP specification does not mention Content-Encoding for
 	// requests, but sometimes it is handy to allow the client to do
@@ -103,51 +76,58 @@ func (data *Data) ReadRequest(ctx context.Context, r *http.Request) error {
 
 	data.ContentType = r.Header.Get("Content-Type")
 	if data.ContentType == "" {
-		data.ContentType = "application/octet-stream"
+		if err := data.DetectContentType(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// ReadRequest reads the data from the request into the raw.Data.
-func (data *Data) ReadResponse(ctx context.Context, r *http.Response) error {
-	if cl := r.Header.Get("Content-Length"); cl != "" {
-		v, err := strconv.ParseInt(cl, 10, 64)
-		if err != nil || v < 0 {
-			return log.Warn("invalid content-length",
-				log.WithValue("content-length", cl),
-				log.WithContext(ctx))
-		}
-
-		if v >= int64(MaxLen) {
-			return log.Warn("max length excceeded",
-				log.WithContext(ctx),
-				log.WithStatusBadRequest(),
-				log.WithValue("MaxLen", MaxLen))
-		}
+// ReadRequestTo streams r.Body into dst, without buffering it into a
+// Data's Content, and returns the number of bytes copied. It does not
+// itself bound how much it reads - wrap r.Body in http.MaxBytesReader
+// first (as ReadRequest does, bounded by MaxLen) with whatever limit
+// suits dst, so a large but legitimate upload can be streamed to, say, a
+// file with a much higher limit than MaxLen.
+func (data *Data) ReadRequestTo(ctx context.Context, r *http.Request, dst io.Writer) (int64, error) {
+	n, err := io.Copy(dst, r.Body)
+	if err == nil {
+		return n, nil
+	}
 
-		buf := make([]byte, v)
+	// A *http.MaxBytesError carries no StatusCode() of its own, so wrap
+	// it in errs.New with the right one - sendError (and anything else
+	// that inspects errors the way it does) needs that to turn this
+	// into a 413 instead of the 500 an unrecognised error gets.
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		log.Warn("max size exceeded", log.WithContext(ctx), log.WithError(err))
+		return n, errs.New("request body too large", http.StatusRequestEntityTooLarge)
+	}
+	log.Warn("cannot read content", log.WithContext(ctx), log.WithError(err))
+	return n, errs.BadRequest("cannot read content")
+}
 
-		_, err = io.ReadFull(r.Body, buf)
-		if err != nil {
-			return log.Warn("cannot read content",
-				log.WithContext(ctx),
-				log.WithError(err))
-		}
-		data.Content = buf
-	} else {
-		reader := io.LimitReader(r.Body, int64(MaxLen))
-		content, err := ioutil.ReadAll(reader)
-		if err != nil {
-			return err
-		}
-		if len(content) >= MaxLen {
-			return log.Warn("max size exceeded",
-				log.WithContext(ctx),
-				log.WithStatusBadRequest(),
-				log.WithValue("MaxLen", MaxLen))
-		}
-		data.Content = content
+// ReadResponse reads the data from a HTTP response into the raw.Data. As
+// with ReadRequest, r.Body is never trusted to actually be
+// Content-Length bytes long: it is read through a reader limited to
+// MaxLen+1, so a response that exceeds MaxLen is rejected rather than
+// pinning unbounded memory, whatever its Content-Length header claims.
+func (data *Data) ReadResponse(ctx context.Context, r *http.Response) error {
+	reader := io.LimitReader(r.Body, int64(MaxLen)+1)
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return log.Warn("cannot read content",
+			log.WithContext(ctx),
+			log.WithError(err))
 	}
+	if len(content) > MaxLen {
+		return log.Warn("max size exceeded",
+			log.WithContext(ctx),
+			log.WithStatusBadRequest(),
+			log.WithValue("MaxLen", MaxLen))
+	}
+	data.Content = content
 
 	// The HTTP specification does not mention Content-Encoding for
 	// requests, but sometimes it is handy to allow the client to do
@@ -162,24 +142,45 @@ func (data *Data) ReadResponse(ctx context.Context, r *http.Response) error {
 
 	data.ContentType = r.Header.Get("Content-Type")
 	if data.ContentType == "" {
-		data.ContentType = "application/octet-stream"
+		if err := data.DetectContentType(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// WriteResponse writes the contents to the client as a response.
+// WriteResponse writes the contents to the client as a response,
+// negotiating the wire Content-Encoding against the request's
+// Accept-Encoding header (see acceptableEncoding). If the stored
+// encoding is acceptable it is sent as-is; otherwise data is transcoded
+// to whichever acceptable, registered encoding (see RegisterEncoding)
+// the client prefers, decompressing to identity if that is what the
+// client prefers or nothing else is registered. If the client accepts
+// none of the available codings, not even identity, the response is a
+// 406 Not Acceptable.
 func (data *Data) WriteResponse(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	// TODO: this is a very naive handling of the Accept-Encoding
-	// header. In particular it does not handle deflate;q=0, which is
-	// a valid way of saying that deflate is not acceptable.
-	if data.IsCompressed() {
-		if ae := r.Header.Get("Accept-Encoding"); !strings.Contains(ae, data.ContentEncoding) {
-			// the user agent does not accept the content encoding, so we
-			// have to decompress before sending
-			err := data.Decompress()
-			if err != nil {
-				return err
-			}
+	data.IsCompressed() // normalizes an empty ContentEncoding to identity
+
+	// A registered encoding is listed ahead of data's current encoding
+	// only when that current encoding is identity: ties then prefer
+	// actually compressing the response over the "free" option of
+	// sending it as-is, whereas ties between two compressed candidates
+	// still prefer whichever data is already stored as, to avoid a
+	// needless transcode.
+	candidates := registeredEncodings()
+	if data.ContentEncoding != ceIdentity {
+		candidates = append([]string{data.ContentEncoding}, candidates...)
+	}
+	candidates = append(dedupeStrings(candidates), ceIdentity)
+
+	wire, ok := acceptableEncoding(r.Header.Get("Accept-Encoding"), candidates)
+	if !ok {
+		http.Error(w, "no content-encoding acceptable to this client", http.StatusNotAcceptable)
+		return nil
+	}
+	if wire != data.ContentEncoding {
+		if err := data.transcode(wire); err != nil {
+			return err
 		}
 	}
 
@@ -220,39 +221,118 @@ func (data *Data) Decompress() error {
 	if !data.IsCompressed() {
 		return nil
 	}
-	input := bytes.NewBuffer(data.Content)
-	var reader io.Reader
-	if data.ContentEncoding == ceDeflate {
-		reader = flate.NewReader(input)
-	} else if data.ContentEncoding == ceGzip {
-		var err error
-		if reader, err = gzip.NewReader(input); err != nil {
-			return err
-		}
-	} else {
-		return log.Error("unknown content-encoding",
-			log.WithValue("content-encoding", data.ContentEncoding))
-	}
-	writer := bytes.Buffer{}
-	_, err := io.Copy(&writer, reader)
+	content, err := data.decompressedContent()
 	if err != nil {
 		return err
 	}
-	data.Content = writer.Bytes()
+	data.Content = content
 	data.ContentEncoding = ""
 	data.UncompressedLength = len(data.Content)
 	return nil
 }
 
+// decompressedContent returns data's content decompressed, if it is
+// compressed, without modifying data - the shared implementation behind
+// Decompress and SniffedType, one of which mutates the struct and one of
+// which must not.
+func (data *Data) decompressedContent() ([]byte, error) {
+	if !data.IsCompressed() {
+		return data.Content, nil
+	}
+	enc, ok := lookupEncoding(data.ContentEncoding)
+	if !ok {
+		return nil, log.Error("unknown content-encoding",
+			log.WithValue("content-encoding", data.ContentEncoding))
+	}
+	reader, err := enc.NewReader(bytes.NewBuffer(data.Content))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, reader); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SniffedType returns the MIME type http.DetectContentType infers from
+// data's decompressed content, without modifying data - so a handler can
+// make a routing or validation decision (rejecting an upload that claims
+// image/png but sniffs as application/x-msdownload, say) before trusting
+// ContentType.
+func (data *Data) SniffedType() (string, error) {
+	content, err := data.decompressedContent()
+	if err != nil {
+		return "", err
+	}
+	return http.DetectContentType(content), nil
+}
+
+// DetectContentType sets data.ContentType to SniffedType's result.
+// ReadRequest and ReadResponse call this in place of the generic
+// application/octet-stream fallback whenever the source had no
+// Content-Type of its own.
+func (data *Data) DetectContentType() error {
+	sniffed, err := data.SniffedType()
+	if err != nil {
+		return err
+	}
+	data.ContentType = sniffed
+	return nil
+}
+
+// Reader returns a reader over data's content, decompressing on the fly
+// if data is compressed, without - unlike Decompress - copying the
+// decompressed bytes into Content. Use this to stream a large payload
+// straight to its destination (an io.Copy to a file or a
+// http.ResponseWriter, say) without holding both the compressed and
+// decompressed forms in memory at once.
+func (data *Data) Reader(ctx context.Context) (io.ReadCloser, error) {
+	if !data.IsCompressed() {
+		return ioutil.NopCloser(bytes.NewReader(data.Content)), nil
+	}
+
+	enc, ok := lookupEncoding(data.ContentEncoding)
+	if !ok {
+		return nil, log.Error("unknown content-encoding",
+			log.WithContext(ctx),
+			log.WithValue("content-encoding", data.ContentEncoding))
+	}
+	reader, err := enc.NewReader(bytes.NewReader(data.Content))
+	if err != nil {
+		return nil, err
+	}
+	if rc, ok := reader.(io.ReadCloser); ok {
+		return rc, nil
+	}
+	return ioutil.NopCloser(reader), nil
+}
+
+// Compress is equivalent to CompressAs(ceDeflate), preserved for
+// callers that predate CompressAs and don't care which coding is used.
 func (data *Data) Compress() error {
+	return data.CompressAs(ceDeflate)
+}
+
+// CompressAs replaces data.Content with its encoded form under encoding
+// (which must be registered, see RegisterEncoding), unless data is
+// already compressed, too short to be worth it, or encoding it does not
+// actually shrink it.
+func (data *Data) CompressAs(encoding string) error {
 	if data.IsCompressed() || len(data.Content) < 32 {
 		// already compressed, or not worth compressing
 		// because data is nil or too short
 		return nil
 	}
 
+	enc, ok := lookupEncoding(encoding)
+	if !ok {
+		return log.Error("unknown content-encoding",
+			log.WithValue("content-encoding", encoding))
+	}
+
 	buf := bytes.Buffer{}
-	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	w, err := enc.NewWriter(&buf)
 	if err != nil {
 		return err
 	}
@@ -272,31 +352,94 @@ func (data *Data) Compress() error {
 	if len(compressedBytes) < len(data.Content) {
 		data.UncompressedLength = len(data.Content)
 		data.Content = compressedBytes
-		data.ContentEncoding = ceDeflate
+		data.ContentEncoding = encoding
 	}
 
 	return nil
 }
 
-func (data *Data) UnmarshalTo(v interface{}) error {
-	err := data.Decompress()
-	if err != nil {
+// transcode re-encodes data from its current (compressed) encoding to
+// wire, by decompressing and then, unless wire is identity, compressing
+// with wire's Encoding - unconditionally, unlike CompressAs, since
+// WriteResponse needs the client's preferred coding on the wire
+// regardless of whether it actually shrinks the payload.
+func (data *Data) transcode(wire string) error {
+	if err := data.Decompress(); err != nil {
 		return err
 	}
-	err = json.Unmarshal(data.Content, v)
+	if wire == ceIdentity {
+		return nil
+	}
+
+	enc, ok := lookupEncoding(wire)
+	if !ok {
+		return log.Error("unknown content-encoding",
+			log.WithValue("content-encoding", wire))
+	}
+	buf := bytes.Buffer{}
+	w, err := enc.NewWriter(&buf)
 	if err != nil {
 		return err
 	}
+	if _, err := w.Write(data.Content); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	data.UncompressedLength = len(data.Content)
+	data.Content = buf.Bytes()
+	data.ContentEncoding = wire
 	return nil
 }
 
+// dedupeStrings returns names with later duplicates of an earlier entry
+// removed, preserving the order of first occurrence.
+func dedupeStrings(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// UnmarshalTo decompresses data, if necessary, and unmarshals its
+// content into v using the Codec registered for data.ContentType (see
+// RegisterCodec), falling back to JSON when ContentType is empty or
+// unrecognised.
+func (data *Data) UnmarshalTo(v interface{}) error {
+	err := data.Decompress()
+	if err != nil {
+		return err
+	}
+	return lookupCodec(data.ContentType).Unmarshal(data.Content, v)
+}
+
+// MarshalFrom marshals v as JSON into data, replacing its content,
+// clearing any compression and setting ContentType to application/json.
+// It is equivalent to MarshalFromAs(v, "application/json").
 func (data *Data) MarshalFrom(v interface{}) error {
-	b, err := json.Marshal(v)
+	return data.MarshalFromAs(v, "application/json")
+}
+
+// MarshalFromAs marshals v into data using the Codec registered for
+// contentType (see RegisterCodec), replacing data's content, clearing
+// any compression and setting data.ContentType to contentType. It lets
+// a single Data round-trip any registered wire format - protobuf, say -
+// from HTTP into storage and back without the caller redoing the
+// compression and length bookkeeping MarshalFrom already handles for
+// JSON.
+func (data *Data) MarshalFromAs(v interface{}, contentType string) error {
+	b, err := lookupCodec(contentType).Marshal(v)
 	if err != nil {
 		return err
 	}
 	data.Content = b
-	data.ContentType = "application/json"
+	data.ContentType = contentType
 	data.ContentEncoding = ""
 	data.UncompressedLength = len(b)
 	return nil
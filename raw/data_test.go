@@ -0,0 +1,229 @@
+package raw
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestCompressAsAndDecompressRoundTrip(t *testing.T) {
+	data := &Data{Content: bytes.Repeat([]byte("hello world "), 10)}
+	if err := data.CompressAs(ceGzip); err != nil {
+		t.Fatalf("CompressAs: %v", err)
+	}
+	if !data.IsCompressed() || data.ContentEncoding != ceGzip {
+		t.Fatalf("expected gzip-compressed content, got encoding %q", data.ContentEncoding)
+	}
+
+	want := strings.Repeat("hello world ", 10)
+	if err := data.Decompress(); err != nil {
+		t.Fatalf("Decompress: %v", err)
+	}
+	if string(data.Content) != want {
+		t.Errorf("got %q, want %q", data.Content, want)
+	}
+}
+
+func TestWriteResponseSendsStoredEncodingWhenAccepted(t *testing.T) {
+	data := &Data{Content: bytes.Repeat([]byte("x"), 64), ContentType: "text/plain"}
+	if err := data.CompressAs(ceGzip); err != nil {
+		t.Fatalf("CompressAs: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip, deflate")
+	w := httptest.NewRecorder()
+
+	if err := data.WriteResponse(context.Background(), w, r); err != nil {
+		t.Fatalf("WriteResponse: %v", err)
+	}
+	if got := w.Header().Get("Content-Encoding"); got != ceGzip {
+		t.Errorf("got Content-Encoding %q, want %q", got, ceGzip)
+	}
+}
+
+func TestWriteResponseCompressesIdentityStoredDataWhenAccepted(t *testing.T) {
+	want := strings.Repeat("z", 64)
+	data := &Data{Content: []byte(want), ContentType: "text/plain"}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+
+	if err := data.WriteResponse(context.Background(), w, r); err != nil {
+		t.Fatalf("WriteResponse: %v", err)
+	}
+	if got := w.Header().Get("Content-Encoding"); got != ceGzip {
+		t.Fatalf("got Content-Encoding %q, want %q", got, ceGzip)
+	}
+
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	defer gz.Close()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, gz); err != nil {
+		t.Fatalf("io.Copy: %v", err)
+	}
+	if buf.String() != want {
+		t.Errorf("got body %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteResponseRejectsIdentityStoredDataWhenIdentityNotAccepted(t *testing.T) {
+	data := &Data{Content: bytes.Repeat([]byte("w"), 64), ContentType: "text/plain"}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip;q=0, identity;q=0")
+	w := httptest.NewRecorder()
+
+	if err := data.WriteResponse(context.Background(), w, r); err != nil {
+		t.Fatalf("WriteResponse: %v", err)
+	}
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusNotAcceptable)
+	}
+}
+
+func TestWriteResponseDecompressesWhenNotAccepted(t *testing.T) {
+	want := strings.Repeat("y", 64)
+	data := &Data{Content: []byte(want), ContentType: "text/plain"}
+	if err := data.CompressAs(ceGzip); err != nil {
+		t.Fatalf("CompressAs: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip;q=0, deflate;q=0, identity")
+	w := httptest.NewRecorder()
+
+	if err := data.WriteResponse(context.Background(), w, r); err != nil {
+		t.Fatalf("WriteResponse: %v", err)
+	}
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("got Content-Encoding %q, want none", got)
+	}
+	if w.Body.String() != want {
+		t.Errorf("got body %q, want %q", w.Body.String(), want)
+	}
+}
+
+func TestWriteResponseReturnsNotAcceptableWhenNothingMatches(t *testing.T) {
+	data := &Data{Content: bytes.Repeat([]byte("z"), 64), ContentType: "text/plain"}
+	if err := data.CompressAs(ceGzip); err != nil {
+		t.Fatalf("CompressAs: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "*;q=0")
+	w := httptest.NewRecorder()
+
+	if err := data.WriteResponse(context.Background(), w, r); err != nil {
+		t.Fatalf("WriteResponse: %v", err)
+	}
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusNotAcceptable)
+	}
+}
+
+func TestReadRequestReadsBody(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	r.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+
+	var data Data
+	if err := data.ReadRequest(context.Background(), w, r); err != nil {
+		t.Fatalf("ReadRequest: %v", err)
+	}
+	if string(data.Content) != "hello" {
+		t.Errorf("got content %q, want %q", data.Content, "hello")
+	}
+	if data.ContentType != "text/plain" {
+		t.Errorf("got content type %q, want %q", data.ContentType, "text/plain")
+	}
+}
+
+func TestReadRequestRejectsOversizedBodyWith413(t *testing.T) {
+	old := MaxLen
+	MaxLen = 4
+	defer func() { MaxLen = old }()
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("this is definitely too long"))
+	w := httptest.NewRecorder()
+
+	var data Data
+	err := data.ReadRequest(context.Background(), w, r)
+	if err == nil {
+		t.Fatal("expected an error for an oversized body")
+	}
+	var withStatus interface{ StatusCode() int }
+	if !errors.As(err, &withStatus) || withStatus.StatusCode() != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected a %d error, got %v", http.StatusRequestEntityTooLarge, err)
+	}
+}
+
+func TestReadRequestToStreamsWithoutBufferingContent(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("streamed"))
+	var dst bytes.Buffer
+
+	var data Data
+	n, err := data.ReadRequestTo(context.Background(), r, &dst)
+	if err != nil {
+		t.Fatalf("ReadRequestTo: %v", err)
+	}
+	if n != 8 || dst.String() != "streamed" {
+		t.Errorf("got (%d, %q), want (8, %q)", n, dst.String(), "streamed")
+	}
+	if data.Content != nil {
+		t.Error("expected ReadRequestTo not to populate Content")
+	}
+}
+
+func TestDataReaderDecompressesOnTheFly(t *testing.T) {
+	want := strings.Repeat("stream me ", 10)
+	data := &Data{Content: []byte(want)}
+	if err := data.CompressAs(ceGzip); err != nil {
+		t.Fatalf("CompressAs: %v", err)
+	}
+
+	rc, err := data.Reader(context.Background())
+	if err != nil {
+		t.Fatalf("Reader: %v", err)
+	}
+	defer rc.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, rc); err != nil {
+		t.Fatalf("io.Copy: %v", err)
+	}
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	// Reader must not have touched data.Content itself.
+	if data.ContentEncoding != ceGzip {
+		t.Error("expected Reader to leave data compressed")
+	}
+}
+
+func TestRegisterEncodingAddsCandidateForNegotiation(t *testing.T) {
+	const name = "test-encoding"
+	RegisterEncoding(name, deflateEncoding{})
+	defer delete(encodings, name)
+
+	found := false
+	for _, n := range registeredEncodings() {
+		if n == name {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected %q among registeredEncodings(), got %v", name, registeredEncodings())
+	}
+}
@@ -0,0 +1,98 @@
+package raw
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+)
+
+// Encoding adapts a content-coding (as used in the Content-Encoding and
+// Accept-Encoding headers) to readers/writers that can produce and
+// consume it, so a Data can transcode between codings. See
+// RegisterEncoding.
+type Encoding interface {
+	NewReader(io.Reader) (io.Reader, error)
+	NewWriter(io.Writer) (io.WriteCloser, error)
+}
+
+// encodings holds every coding Compress, Decompress and WriteResponse
+// know how to produce and consume. gzip and deflate are registered by
+// default; identity is handled specially and is never in this map.
+var encodings = map[string]Encoding{
+	ceGzip:    gzipEncoding{},
+	ceDeflate: deflateEncoding{},
+}
+
+// RegisterEncoding makes enc available, under name, to Compress,
+// CompressAs, Decompress and WriteResponse, in addition to the gzip and
+// deflate codings this package already knows. Call it from an init
+// function, for example to add brotli or zstd support by wrapping a
+// third-party package:
+//
+//	raw.RegisterEncoding("br", brotliEncoding{})
+//
+// KNOWN GAP: br and zstd were requested alongside this registry but are
+// not implemented here. Both need a real compressor/decompressor
+// (brotli and zstd have no stdlib or pure-Go-without-dependencies
+// implementation worth trusting for wire compatibility), and this
+// snapshot has no go.mod through which to pull one in and no vendored
+// copy either. Rather than fake it, this is left as a genuine TODO for
+// whoever next touches this package with a dependency story to wire
+// one in: RegisterEncoding is exactly the seam to hang it from.
+func RegisterEncoding(name string, enc Encoding) {
+	encodings[name] = enc
+}
+
+// lookupEncoding returns the Encoding registered under name, if any.
+func lookupEncoding(name string) (Encoding, bool) {
+	enc, ok := encodings[name]
+	return enc, ok
+}
+
+// preferredEncodingOrder lists well-known codings in the order
+// registeredEncodings should prefer them, best compression ratio first,
+// when a client accepts more than one equally. Names not in this list
+// (a coding registered by the caller that this package does not know
+// about) are appended afterwards, in map iteration order.
+var preferredEncodingOrder = []string{"zstd", "br", ceGzip, ceDeflate}
+
+// registeredEncodings returns the names of every coding RegisterEncoding
+// (or this package's own init) has made available, in server preference
+// order, for use as negotiation candidates alongside a Data's current
+// encoding.
+func registeredEncodings() []string {
+	names := make([]string, 0, len(encodings))
+	seen := make(map[string]bool, len(encodings))
+	for _, name := range preferredEncodingOrder {
+		if _, ok := encodings[name]; ok {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	for name := range encodings {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type gzipEncoding struct{}
+
+func (gzipEncoding) NewReader(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
+
+func (gzipEncoding) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, gzip.BestCompression)
+}
+
+type deflateEncoding struct{}
+
+func (deflateEncoding) NewReader(r io.Reader) (io.Reader, error) {
+	return flate.NewReader(r), nil
+}
+
+func (deflateEncoding) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return flate.NewWriter(w, flate.BestCompression)
+}
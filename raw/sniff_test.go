@@ -0,0 +1,65 @@
+package raw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestSniffedTypeDetectsFromContent(t *testing.T) {
+	data := &Data{Content: []byte("<html><body>hi</body></html>")}
+	got, err := data.SniffedType()
+	if err != nil {
+		t.Fatalf("SniffedType: %v", err)
+	}
+	if !strings.HasPrefix(got, "text/html") {
+		t.Errorf("got %q, want a text/html type", got)
+	}
+	if data.ContentType != "" {
+		t.Error("SniffedType must not modify data.ContentType")
+	}
+}
+
+func TestSniffedTypeDecompressesFirst(t *testing.T) {
+	data := &Data{Content: []byte(strings.Repeat("<html><body>hi</body></html>", 4))}
+	if err := data.CompressAs(ceGzip); err != nil {
+		t.Fatalf("CompressAs: %v", err)
+	}
+
+	got, err := data.SniffedType()
+	if err != nil {
+		t.Fatalf("SniffedType: %v", err)
+	}
+	if !strings.HasPrefix(got, "text/html") {
+		t.Errorf("got %q, want a text/html type", got)
+	}
+	if !data.IsCompressed() {
+		t.Error("SniffedType must not decompress data itself")
+	}
+}
+
+func TestDetectContentTypeSetsContentType(t *testing.T) {
+	data := &Data{Content: []byte("%PDF-1.4 not a real pdf but starts like one")}
+	if err := data.DetectContentType(); err != nil {
+		t.Fatalf("DetectContentType: %v", err)
+	}
+	if !strings.HasPrefix(data.ContentType, "application/pdf") {
+		t.Errorf("got %q, want an application/pdf type", data.ContentType)
+	}
+}
+
+func TestReadRequestDetectsContentTypeWhenMissing(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("<html><body>hi</body></html>"))
+	w := httptest.NewRecorder()
+
+	var data Data
+	if err := data.ReadRequest(context.Background(), w, r); err != nil {
+		t.Fatalf("ReadRequest: %v", err)
+	}
+	if !strings.HasPrefix(data.ContentType, "text/html") {
+		t.Errorf("got %q, want a sniffed text/html type", data.ContentType)
+	}
+}
@@ -0,0 +1,130 @@
+package raw
+
+import (
+	"io"
+	"net/http"
+
+	"golang.org/x/net/context"
+	"sp.com.au/exp/log"
+)
+
+// StreamThreshold is the content length at or above which
+// WriteResponseStream streams a transcoded response through a chunked,
+// periodically flushed Encoding.NewWriter, instead of buffering the
+// whole re-encoded payload into memory the way WriteResponse does.
+// Smaller payloads, and any payload that needs no transcoding, are
+// cheaper to serve buffered, so they still go through WriteResponse.
+var StreamThreshold = 1 << 20 // 1 MiB
+
+// WriteResponseStream is like WriteResponse, except that when the
+// negotiated wire encoding (see acceptableEncoding) differs from data's
+// stored encoding - including compressing identity-stored data on the
+// fly for a client that accepts gzip or deflate - and data.Content is at
+// least StreamThreshold bytes, it streams the transcoded response -
+// reading from data.Reader, writing through the target Encoding's
+// writer, flushing after every chunk via http.Flusher - rather than
+// building the whole re-encoded response in memory first, as a multi-MB
+// JSON response would otherwise require.
+//
+// Every other case (a small payload, or one that needs no transcoding)
+// is delegated straight to WriteResponse, since buffering is cheaper
+// there.
+func (data *Data) WriteResponseStream(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	if len(data.Content) < StreamThreshold {
+		return data.WriteResponse(ctx, w, r)
+	}
+	data.IsCompressed() // normalizes an empty ContentEncoding to identity
+
+	// A registered encoding is listed ahead of data's current encoding
+	// only when that current encoding is identity: ties then prefer
+	// actually compressing the response over the "free" option of
+	// sending it as-is, whereas ties between two compressed candidates
+	// still prefer whichever data is already stored as, to avoid a
+	// needless transcode.
+	candidates := registeredEncodings()
+	if data.ContentEncoding != ceIdentity {
+		candidates = append([]string{data.ContentEncoding}, candidates...)
+	}
+	candidates = append(dedupeStrings(candidates), ceIdentity)
+
+	wire, ok := acceptableEncoding(r.Header.Get("Accept-Encoding"), candidates)
+	if !ok {
+		http.Error(w, "no content-encoding acceptable to this client", http.StatusNotAcceptable)
+		return nil
+	}
+	if wire == data.ContentEncoding {
+		// Nothing to transcode, so there is nothing to stream either;
+		// the buffered path already sends this encoding as-is.
+		return data.WriteResponse(ctx, w, r)
+	}
+
+	src, err := data.Reader(ctx)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var dst io.Writer = w
+	var encWriter io.WriteCloser
+	if wire != ceIdentity {
+		enc, ok := lookupEncoding(wire)
+		if !ok {
+			return log.Error("unknown content-encoding",
+				log.WithContext(ctx),
+				log.WithValue("content-encoding", wire))
+		}
+		if encWriter, err = enc.NewWriter(w); err != nil {
+			return err
+		}
+		dst = encWriter
+	}
+
+	if wire == ceIdentity {
+		w.Header().Del("Content-Encoding")
+	} else {
+		w.Header().Set("Content-Encoding", wire)
+	}
+	w.Header().Set("Content-Type", data.ContentType)
+	w.Header().Del("Content-Length")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+
+	fw := &flushWriter{w: dst}
+	if f, ok := w.(http.Flusher); ok {
+		fw.flusher = f
+	}
+
+	if _, err := io.Copy(fw, src); err != nil {
+		// As with WriteResponse, there is no way to tell the client
+		// anything has gone wrong once we have already started
+		// writing the response, so just log it.
+		log.Warn("cannot write response", log.WithError(err), log.WithContext(ctx))
+		return nil
+	}
+	if encWriter != nil {
+		if err := encWriter.Close(); err != nil {
+			log.Warn("cannot close response encoder", log.WithError(err), log.WithContext(ctx))
+		}
+	}
+	if fw.flusher != nil {
+		fw.flusher.Flush()
+	}
+	return nil
+}
+
+// flushWriter wraps w, flushing the underlying http.ResponseWriter after
+// every write (if it implements http.Flusher), so a streamed response
+// reaches the client incrementally instead of sitting behind Go's
+// internal write buffering until the handler returns.
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if fw.flusher != nil {
+		fw.flusher.Flush()
+	}
+	return n, err
+}
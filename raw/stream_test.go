@@ -0,0 +1,134 @@
+package raw
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestWriteResponseStreamBelowThresholdUsesBufferedPath(t *testing.T) {
+	old := StreamThreshold
+	StreamThreshold = 1 << 20
+	defer func() { StreamThreshold = old }()
+
+	want := strings.Repeat("small ", 8)
+	data := &Data{Content: []byte(want), ContentType: "text/plain"}
+	if err := data.CompressAs(ceGzip); err != nil {
+		t.Fatalf("CompressAs: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip;q=0, deflate;q=0, identity")
+	w := httptest.NewRecorder()
+
+	if err := data.WriteResponseStream(context.Background(), w, r); err != nil {
+		t.Fatalf("WriteResponseStream: %v", err)
+	}
+	if w.Header().Get("Transfer-Encoding") == "chunked" {
+		t.Error("did not expect a chunked response below StreamThreshold")
+	}
+	if w.Body.String() != want {
+		t.Errorf("got body %q, want %q", w.Body.String(), want)
+	}
+}
+
+func TestWriteResponseStreamTranscodesAboveThreshold(t *testing.T) {
+	old := StreamThreshold
+	StreamThreshold = 16
+	defer func() { StreamThreshold = old }()
+
+	want := strings.Repeat("stream this payload ", 20)
+	data := &Data{Content: []byte(want), ContentType: "text/plain"}
+	if err := data.CompressAs(ceDeflate); err != nil {
+		t.Fatalf("CompressAs: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "deflate;q=0, gzip")
+	w := httptest.NewRecorder()
+
+	if err := data.WriteResponseStream(context.Background(), w, r); err != nil {
+		t.Fatalf("WriteResponseStream: %v", err)
+	}
+	if got := w.Header().Get("Content-Encoding"); got != ceGzip {
+		t.Fatalf("got Content-Encoding %q, want %q", got, ceGzip)
+	}
+
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	defer gz.Close()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, gz); err != nil {
+		t.Fatalf("io.Copy: %v", err)
+	}
+	if buf.String() != want {
+		t.Errorf("got body %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteResponseStreamCompressesIdentityOnTheFly(t *testing.T) {
+	old := StreamThreshold
+	StreamThreshold = 16
+	defer func() { StreamThreshold = old }()
+
+	want := strings.Repeat("compress this identity payload ", 20)
+	data := &Data{Content: []byte(want), ContentType: "text/plain"}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+
+	if err := data.WriteResponseStream(context.Background(), w, r); err != nil {
+		t.Fatalf("WriteResponseStream: %v", err)
+	}
+	if got := w.Header().Get("Content-Encoding"); got != ceGzip {
+		t.Fatalf("got Content-Encoding %q, want %q", got, ceGzip)
+	}
+
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	defer gz.Close()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, gz); err != nil {
+		t.Fatalf("io.Copy: %v", err)
+	}
+	if buf.String() != want {
+		t.Errorf("got body %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteResponseStreamNoTranscodeDelegatesToBufferedPath(t *testing.T) {
+	old := StreamThreshold
+	StreamThreshold = 16
+	defer func() { StreamThreshold = old }()
+
+	want := strings.Repeat("already the right encoding ", 10)
+	data := &Data{Content: []byte(want), ContentType: "text/plain"}
+	if err := data.CompressAs(ceGzip); err != nil {
+		t.Fatalf("CompressAs: %v", err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+
+	if err := data.WriteResponseStream(context.Background(), w, r); err != nil {
+		t.Fatalf("WriteResponseStream: %v", err)
+	}
+	if w.Header().Get("Transfer-Encoding") == "chunked" {
+		t.Error("expected the buffered path (no transcoding needed), not a chunked response")
+	}
+	if got := w.Header().Get("Content-Encoding"); got != ceGzip {
+		t.Errorf("got Content-Encoding %q, want %q", got, ceGzip)
+	}
+}
@@ -2,9 +2,15 @@ package httpctx
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"runtime"
 	"strconv"
 	"strings"
+
+	"sp.com.au/exp/env"
+	"sp.com.au/exp/errs"
 )
 
 // shouldSendJson decides whether it is appropriate to send a JSON
@@ -22,10 +28,10 @@ func shouldSendJSON(r *http.Request) bool {
 }
 
 // sendError sends an error message back to the client.
-// Note that the error returned to the client contains the
-// message returned by err.Error(). It is the calling program's
-// responsibility not to return sensitive information in this
-// error message.
+// Note that, unless err wraps an errs.Visible error (see errs.Visible),
+// the error returned to the client contains the message returned by
+// err.Error(). It is the calling program's responsibility not to return
+// sensitive information in this error message.
 func sendError(w http.ResponseWriter, r *http.Request, err error) {
 	statusCode := http.StatusInternalServerError
 	if errWithStatusCode, ok := err.(interface {
@@ -46,6 +52,27 @@ func sendError(w http.ResponseWriter, r *http.Request, err error) {
 		code = errWithCode.Code()
 	}
 
+	// If the error chain carries an errs.Visible layer, only its message
+	// is safe to show the client; the original cause stays available to
+	// the caller via errors.Unwrap for logging/tracing.
+	message := err.Error()
+	if visible, ok := errs.AsVisible(err); ok {
+		message = visible
+	}
+
+	// In development, also surface the call stack captured when the
+	// error was created (see errs.StackTracer), so that whoever is
+	// driving the request can diagnose a 500 without a separate tracing
+	// layer. This never happens outside development, since the stack
+	// can reveal internal file paths and package layout.
+	var stack []string
+	if env.IsDevelopment() {
+		var st errs.StackTracer
+		if errors.As(err, &st) {
+			stack = formatStack(st.StackTrace())
+		}
+	}
+
 	// remove headers that might have been set upstream
 	w.Header().Del("Content-Encoding")
 
@@ -57,13 +84,16 @@ func sendError(w http.ResponseWriter, r *http.Request, err error) {
 		// {"error":{"message":"message-here","code":"xyz123","status":400}}
 		resp := map[string]map[string]interface{}{
 			"error": {
-				"message": err.Error(),
+				"message": message,
 				"status":  statusCode,
 			},
 		}
 		if code != "" {
 			resp["error"]["code"] = code
 		}
+		if len(stack) > 0 {
+			resp["error"]["stack"] = stack
+		}
 
 		// If this does not succeed, then all we can do is to
 		// send back the status code to the client, but cannot
@@ -81,6 +111,19 @@ func sendError(w http.ResponseWriter, r *http.Request, err error) {
 			w.Write(b)
 		}
 	} else {
-		http.Error(w, err.Error(), statusCode)
+		if len(stack) > 0 {
+			message += "\n" + strings.Join(stack, "\n")
+		}
+		http.Error(w, message, statusCode)
+	}
+}
+
+// formatStack renders frames as "file:line function" strings, innermost
+// frame first, for inclusion in a development-only error response.
+func formatStack(frames []runtime.Frame) []string {
+	lines := make([]string, len(frames))
+	for i, frame := range frames {
+		lines[i] = fmt.Sprintf("%s:%d %s", frame.File, frame.Line, frame.Function)
 	}
+	return lines
 }
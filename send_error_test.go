@@ -1,11 +1,16 @@
 package httpctx
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+
+	"sp.com.au/exp/env"
+	"sp.com.au/exp/errs"
 )
 
 func TestShouldSendJSON(t *testing.T) {
@@ -61,6 +66,42 @@ func TestSendError(t *testing.T) {
 	}
 }
 
+func TestSendErrorIncludesStackInDevelopment(t *testing.T) {
+	prevName := env.Name()
+	defer env.MustSetName(prevName)
+
+	env.MustSetName(env.TestPrefix)
+	err := errs.New("boom", http.StatusInternalServerError)
+
+	rr := httptest.NewRecorder()
+	sendError(rr, &http.Request{URL: &url.URL{}, Header: http.Header{}}, err)
+	if strings.Contains(rr.Body.String(), "send_error_test.go") {
+		t.Errorf("stack should not be included outside development, got %q", rr.Body.String())
+	}
+
+	env.MustSetName(env.DevelopmentPrefix)
+
+	rr = httptest.NewRecorder()
+	r := &http.Request{URL: &url.URL{}, Header: http.Header{"Accept": {"application/json"}}}
+	sendError(rr, r, err)
+
+	var resp struct {
+		Error struct {
+			Message string   `json:"message"`
+			Stack   []string `json:"stack"`
+		} `json:"error"`
+	}
+	if jsonErr := json.Unmarshal(rr.Body.Bytes(), &resp); jsonErr != nil {
+		t.Fatalf("could not unmarshal response %q: %v", rr.Body.String(), jsonErr)
+	}
+	if len(resp.Error.Stack) == 0 {
+		t.Errorf("expected a non-empty stack in development, got %q", rr.Body.String())
+	}
+	if !strings.Contains(resp.Error.Stack[0], "send_error_test.go") {
+		t.Errorf("expected innermost frame to be this test, got %q", resp.Error.Stack[0])
+	}
+}
+
 type HTTPErrorCode struct {
 	Err        error
 	StatusCode string
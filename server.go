@@ -0,0 +1,144 @@
+package httpctx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"sp.com.au/exp/errs"
+)
+
+// Endpoint is a go-kit style business-logic function: given a decoded
+// request, it returns a response or an error. It knows nothing about HTTP;
+// see Server for the glue that turns one into a Handler.
+type Endpoint[Req, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+// DecodeRequestFunc decodes a HTTP request into a Req, for use as
+// Server.Decode. See JSONDecoder for a ready-made implementation.
+type DecodeRequestFunc[Req any] func(ctx context.Context, r *http.Request) (Req, error)
+
+// EncodeResponseFunc writes a Resp to the HTTP client, for use as
+// Server.Encode. See JSONEncoder for a ready-made implementation.
+type EncodeResponseFunc[Resp any] func(ctx context.Context, w http.ResponseWriter, resp Resp) error
+
+// BeforeFunc is called before Server.Decode, and may return a new context
+// to be used for the rest of the request, for example to add a value
+// extracted from r.
+type BeforeFunc func(ctx context.Context, r *http.Request) context.Context
+
+// AfterFunc is called after Server.Endpoint has returned successfully, and
+// before Server.Encode, and may return a new context to be used for the
+// remainder of the request, for example to set a header on w.
+type AfterFunc func(ctx context.Context, w http.ResponseWriter) context.Context
+
+// Server adapts an Endpoint to the httpctx.Handler interface, handling the
+// request decoding and response encoding either side of it. Since
+// Server[Req, Resp] satisfies Handler, it can be used with Handle,
+// HandleFunc, and Stack just like a HandlerFunc.
+type Server[Req, Resp any] struct {
+	// Decode decodes the incoming request. Required.
+	Decode DecodeRequestFunc[Req]
+
+	// Endpoint implements the request. Required.
+	Endpoint Endpoint[Req, Resp]
+
+	// Encode writes the response returned by Endpoint to the client. Required.
+	Encode EncodeResponseFunc[Resp]
+
+	// Before, if non-empty, is called in order before Decode.
+	Before []BeforeFunc
+
+	// After, if non-empty, is called in order after Endpoint, before Encode.
+	After []AfterFunc
+
+	// ErrorEncoder, if non-nil, handles any error returned by Decode,
+	// Endpoint or Encode directly, instead of letting it propagate to the
+	// enclosing Handle, HandleFunc or Stack.
+	ErrorEncoder ErrorEncoder
+}
+
+var _ Handler = Server[any, any]{}
+
+// ServeHTTPContext runs s.Before, s.Decode, s.Endpoint, s.After and
+// s.Encode in turn, stopping at the first error. If s.ErrorEncoder is set,
+// it is used to write that error to w and ServeHTTPContext returns nil;
+// otherwise the error is returned so that the caller (typically Handle or
+// a Stack) can encode it instead.
+func (s Server[Req, Resp]) ServeHTTPContext(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	for _, before := range s.Before {
+		ctx = before(ctx, r)
+	}
+
+	req, err := s.Decode(ctx, r)
+	if err != nil {
+		return s.encodeError(ctx, w, r, err)
+	}
+
+	resp, err := s.Endpoint(ctx, req)
+	if err != nil {
+		return s.encodeError(ctx, w, r, err)
+	}
+
+	for _, after := range s.After {
+		ctx = after(ctx, w)
+	}
+
+	if err := s.Encode(ctx, w, resp); err != nil {
+		return s.encodeError(ctx, w, r, err)
+	}
+
+	return nil
+}
+
+// encodeError routes err through s.ErrorEncoder, if set, returning nil
+// since the response has already been written; otherwise it returns err
+// unchanged, for the caller to handle.
+func (s Server[Req, Resp]) encodeError(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) error {
+	if s.ErrorEncoder == nil {
+		return err
+	}
+	s.ErrorEncoder(ctx, w, r, err)
+	return nil
+}
+
+// defaultMaxRequestBytes is the request body size above which JSONDecoder
+// gives up with errs.BadRequest, if maxBytes is zero.
+const defaultMaxRequestBytes = 1 << 20 // 1 MiB
+
+// JSONDecoder returns a DecodeRequestFunc that rejects any request with a
+// Content-Type that does not start with "application/json" (a request
+// with no Content-Type is accepted), and otherwise decodes a JSON request body of up to maxBytes into a Req. A
+// maxBytes of zero uses defaultMaxRequestBytes.
+func JSONDecoder[Req any](maxBytes int64) DecodeRequestFunc[Req] {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxRequestBytes
+	}
+	return func(ctx context.Context, r *http.Request) (Req, error) {
+		var req Req
+		if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+			return req, errs.BadRequest(fmt.Sprintf("unsupported content type %q, expected application/json", ct))
+		}
+		dec := json.NewDecoder(io.LimitReader(r.Body, maxBytes))
+		if err := dec.Decode(&req); err != nil {
+			return req, errs.BadRequest(fmt.Sprintf("cannot decode request body: %v", err))
+		}
+		return req, nil
+	}
+}
+
+// JSONEncoder returns an EncodeResponseFunc that writes resp to w as a
+// "application/json" response. If pretty is true, the JSON is indented for
+// readability, which is useful during development.
+func JSONEncoder[Resp any](pretty bool) EncodeResponseFunc[Resp] {
+	return func(ctx context.Context, w http.ResponseWriter, resp Resp) error {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		if pretty {
+			enc.SetIndent("", "  ")
+		}
+		return enc.Encode(resp)
+	}
+}
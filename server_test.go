@@ -0,0 +1,145 @@
+package httpctx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sp.com.au/exp/errs"
+)
+
+type greetRequest struct {
+	Name string `json:"name"`
+}
+
+type greetResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+func greet(ctx context.Context, req greetRequest) (greetResponse, error) {
+	if req.Name == "" {
+		return greetResponse{}, errs.BadRequest("name is required")
+	}
+	return greetResponse{Greeting: "hello, " + req.Name}, nil
+}
+
+func TestServerServeHTTPContext(t *testing.T) {
+	server := Server[greetRequest, greetResponse]{
+		Decode:   JSONDecoder[greetRequest](0),
+		Endpoint: greet,
+		Encode:   JSONEncoder[greetResponse](false),
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"gopher"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	if err := server.ServeHTTPContext(context.Background(), w, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var resp greetResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("could not unmarshal response %q: %v", w.Body.String(), err)
+	}
+	if resp.Greeting != "hello, gopher" {
+		t.Errorf("got greeting %q, want %q", resp.Greeting, "hello, gopher")
+	}
+}
+
+func TestServerServeHTTPContextReturnsEndpointError(t *testing.T) {
+	server := Server[greetRequest, greetResponse]{
+		Decode:   JSONDecoder[greetRequest](0),
+		Endpoint: greet,
+		Encode:   JSONEncoder[greetResponse](false),
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	err := server.ServeHTTPContext(context.Background(), w, r)
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+	if errs.Code(err) != "" {
+		t.Errorf("unexpected error code %q", errs.Code(err))
+	}
+}
+
+func TestServerServeHTTPContextWithErrorEncoder(t *testing.T) {
+	var encoded error
+	server := Server[greetRequest, greetResponse]{
+		Decode:   JSONDecoder[greetRequest](0),
+		Endpoint: greet,
+		Encode:   JSONEncoder[greetResponse](false),
+		ErrorEncoder: func(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+			encoded = err
+			sendError(w, r, err)
+		},
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	if err := server.ServeHTTPContext(context.Background(), w, r); err != nil {
+		t.Fatalf("expected ErrorEncoder to absorb the error, got %v", err)
+	}
+	if encoded == nil {
+		t.Fatal("ErrorEncoder was not called")
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestServerServeHTTPContextBeforeAfter(t *testing.T) {
+	type ctxKey struct{}
+
+	var afterSawBefore bool
+	server := Server[greetRequest, greetResponse]{
+		Before: []BeforeFunc{
+			func(ctx context.Context, r *http.Request) context.Context {
+				return context.WithValue(ctx, ctxKey{}, "gopher")
+			},
+		},
+		Decode: JSONDecoder[greetRequest](0),
+		Endpoint: func(ctx context.Context, req greetRequest) (greetResponse, error) {
+			return greetResponse{Greeting: ctx.Value(ctxKey{}).(string)}, nil
+		},
+		After: []AfterFunc{
+			func(ctx context.Context, w http.ResponseWriter) context.Context {
+				afterSawBefore = ctx.Value(ctxKey{}) == "gopher"
+				return ctx
+			},
+		},
+		Encode: JSONEncoder[greetResponse](false),
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{}`)))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	if err := server.ServeHTTPContext(context.Background(), w, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !afterSawBefore {
+		t.Error("After hook did not see the value set by the Before hook")
+	}
+}
+
+func TestJSONDecoderRejectsWrongContentType(t *testing.T) {
+	decode := JSONDecoder[greetRequest](0)
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"gopher"}`))
+	r.Header.Set("Content-Type", "text/plain")
+
+	_, err := decode(context.Background(), r)
+	if err == nil {
+		t.Fatal("expected an error for the wrong content type")
+	}
+}
@@ -0,0 +1,104 @@
+package httpctx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sp.com.au/exp/errs"
+)
+
+// WithTimeout returns middleware that derives a context.WithTimeout from
+// the request's context, so its Done channel closes no later than d
+// after the request started, in addition to closing when the client
+// disconnects (see newContext, which this layers on top of via Context
+// and Use). If the wrapped Handler returns context.DeadlineExceeded, or
+// the deadline expires before it returns, the request is turned into a
+// 504 Gateway Timeout via errs.Visible, so it flows through the usual
+// sendError/AccessLog/Metrics path like any other error - put AccessLog
+// and Metrics ahead of WithTimeout (or WithDeadlineHeader) in the stack,
+// so that they wrap it and record the 504.
+func WithTimeout(d time.Duration) func(Handler) Handler {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return runWithDeadline(ctx, h, w, r)
+		})
+	}
+}
+
+// WithDeadlineHeader returns middleware like WithTimeout, except that the
+// timeout for each request is read from the named request header,
+// clamped to max. The header may carry a plain number of milliseconds
+// (the X-Request-Deadline-Ms convention) or a gRPC-style timeout, a
+// number followed by a unit suffix (see parseDeadline); a missing,
+// zero, negative or unparsable value uses max.
+func WithDeadlineHeader(header string, max time.Duration) func(Handler) Handler {
+	return func(h Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			d := max
+			if requested, ok := parseDeadline(r.Header.Get(header)); ok && requested < d {
+				d = requested
+			}
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return runWithDeadline(ctx, h, w, r)
+		})
+	}
+}
+
+// runWithDeadline runs h with ctx, translating context.DeadlineExceeded -
+// whether returned directly by h, or only observable via ctx.Err() once
+// it returns - into a 504 error via errs.Visible, so the client sees a
+// generic timeout message rather than whatever h was doing when it ran
+// out of time.
+func runWithDeadline(ctx context.Context, h Handler, w http.ResponseWriter, r *http.Request) error {
+	err := h.ServeHTTPContext(ctx, w, r)
+	if err == nil && ctx.Err() == context.DeadlineExceeded {
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errs.Visible("request timed out", http.StatusGatewayTimeout, err)
+	}
+	return err
+}
+
+// grpcTimeoutUnits maps a gRPC-style timeout header's single-letter unit
+// suffix (see https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md)
+// to the Duration it represents.
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// parseDeadline interprets raw as a request timeout: either a plain
+// positive number of milliseconds (the X-Request-Deadline-Ms
+// convention), or a gRPC-style timeout, a positive number followed by
+// one of grpcTimeoutUnits's unit suffixes (as in the grpc-timeout
+// header). It returns false if raw is empty or matches neither form.
+func parseDeadline(raw string) (time.Duration, bool) {
+	if raw == "" {
+		return 0, false
+	}
+
+	if unit, ok := grpcTimeoutUnits[raw[len(raw)-1]]; ok {
+		n, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		if err != nil || n <= 0 {
+			return 0, false
+		}
+		return time.Duration(n) * unit, true
+	}
+
+	ms, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
@@ -0,0 +1,154 @@
+package httpctx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithTimeoutAllowsFastHandler(t *testing.T) {
+	h := HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	err := WithTimeout(time.Second)(h).ServeHTTPContext(context.Background(), w, r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestWithTimeoutConvertsSlowHandlerToGatewayTimeout(t *testing.T) {
+	h := HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	err := WithTimeout(time.Millisecond)(h).ServeHTTPContext(context.Background(), w, r)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	var withStatus interface{ StatusCode() int }
+	if !errors.As(err, &withStatus) {
+		t.Fatalf("expected an error with a StatusCode, got %T: %v", err, err)
+	}
+	if withStatus.StatusCode() != http.StatusGatewayTimeout {
+		t.Errorf("got status %d, want %d", withStatus.StatusCode(), http.StatusGatewayTimeout)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("expected the error to wrap context.DeadlineExceeded")
+	}
+}
+
+func TestWithTimeoutConvertsHandlerThatIgnoresDeadline(t *testing.T) {
+	h := HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	err := WithTimeout(time.Millisecond)(h).ServeHTTPContext(context.Background(), w, r)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a DeadlineExceeded-wrapping error even though h returned nil, got %v", err)
+	}
+}
+
+func TestWithDeadlineHeaderUsesMaxWhenHeaderMissing(t *testing.T) {
+	var gotDeadline time.Time
+	h := HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		gotDeadline, _ = ctx.Deadline()
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	if err := WithDeadlineHeader("X-Request-Deadline-Ms", time.Second)(h).
+		ServeHTTPContext(context.Background(), w, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := gotDeadline.Sub(start); d < 900*time.Millisecond || d > 1100*time.Millisecond {
+		t.Errorf("got deadline %v from start, want ~1s", d)
+	}
+}
+
+func TestWithDeadlineHeaderClampsToMax(t *testing.T) {
+	var gotDeadline time.Time
+	h := HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		gotDeadline, _ = ctx.Deadline()
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-Deadline-Ms", "60000")
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	if err := WithDeadlineHeader("X-Request-Deadline-Ms", 100*time.Millisecond)(h).
+		ServeHTTPContext(context.Background(), w, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := gotDeadline.Sub(start); d > 200*time.Millisecond {
+		t.Errorf("got deadline %v from start, want clamped to ~100ms", d)
+	}
+}
+
+func TestWithDeadlineHeaderHonoursShorterClientRequest(t *testing.T) {
+	var gotDeadline time.Time
+	h := HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		gotDeadline, _ = ctx.Deadline()
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("grpc-timeout", "50m")
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	if err := WithDeadlineHeader("grpc-timeout", time.Second)(h).
+		ServeHTTPContext(context.Background(), w, r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := gotDeadline.Sub(start); d > 150*time.Millisecond {
+		t.Errorf("got deadline %v from start, want ~50ms", d)
+	}
+}
+
+func TestParseDeadline(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want time.Duration
+		ok   bool
+	}{
+		{"", 0, false},
+		{"500", 500 * time.Millisecond, true},
+		{"2S", 2 * time.Second, true},
+		{"100m", 100 * time.Millisecond, true},
+		{"1H", time.Hour, true},
+		{"not-a-number", 0, false},
+		{"-5", 0, false},
+		{"0", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseDeadline(tt.raw)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("parseDeadline(%q) = %v, %v; want %v, %v", tt.raw, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package varz
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are power-of-two millisecond boundaries from 1ms up to
+// roughly 30s, a reasonable default for HTTP request latency.
+var DefaultBuckets = func() []time.Duration {
+	var bounds []time.Duration
+	for d := time.Millisecond; d <= 32768*time.Millisecond; d *= 2 {
+		bounds = append(bounds, d)
+	}
+	return bounds
+}()
+
+// Histogram is a cumulative latency histogram with fixed bucket upper
+// bounds, in the style of a Prometheus histogram: Snapshot's counts are
+// cumulative (the count for a bound includes every observation at or
+// below it), with an implicit "+Inf" bucket equal to the total count.
+//
+// A Histogram implements expvar.Var (via String), so it can be published
+// directly with expvar.Publish or an expvar.Map's Set.
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []time.Duration
+	counts []int64
+	total  int64
+	sum    time.Duration
+}
+
+// NewHistogram returns a Histogram with the given bucket upper bounds,
+// which need not be sorted. DefaultBuckets is used if bounds is empty.
+func NewHistogram(bounds []time.Duration) *Histogram {
+	if len(bounds) == 0 {
+		bounds = DefaultBuckets
+	}
+	sorted := append([]time.Duration(nil), bounds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return &Histogram{bounds: sorted, counts: make([]int64, len(sorted))}
+}
+
+// Observe records d, incrementing every bucket whose bound is at or
+// above d.
+func (h *Histogram) Observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.total++
+	h.sum += d
+	for i, bound := range h.bounds {
+		if d <= bound {
+			h.counts[i]++
+		}
+	}
+}
+
+// Snapshot returns the histogram's bucket bounds and cumulative counts
+// (parallel slices), the total number of observations, and their sum, as
+// of the time of the call.
+func (h *Histogram) Snapshot() (bounds []time.Duration, counts []int64, total int64, sum time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]time.Duration(nil), h.bounds...), append([]int64(nil), h.counts...), h.total, h.sum
+}
+
+// String implements expvar.Var, rendering the histogram as a JSON object
+// with cumulative bucket counts keyed by their upper bound, the total
+// observation count, and the sum of observations in milliseconds.
+func (h *Histogram) String() string {
+	bounds, counts, total, sum := h.Snapshot()
+
+	buckets := make(map[string]int64, len(bounds))
+	for i, bound := range bounds {
+		buckets[bound.String()] = counts[i]
+	}
+
+	data := struct {
+		Buckets map[string]int64 `json:"buckets"`
+		Count   int64            `json:"count"`
+		SumMS   float64          `json:"sum_ms"`
+	}{buckets, total, sum.Seconds() * 1000}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
@@ -0,0 +1,44 @@
+package varz
+
+import (
+	"sort"
+	"strings"
+)
+
+// Labels is a set of name/value pairs attached to one entry of a
+// labelled counter (see Registry.IncRequestCount). Its String method is
+// used as the expvar.Map key for that entry, and is parsed back by
+// MetricsHandler when rendering Prometheus exposition format.
+type Labels map[string]string
+
+// String renders labels as "name=value" pairs, comma-separated, sorted
+// by name so that the same label set always produces the same key.
+func (l Labels) String() string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + l[name]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// parseLabels reverses Labels.String.
+func parseLabels(s string) Labels {
+	if s == "" {
+		return nil
+	}
+	labels := Labels{}
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		labels[name] = value
+	}
+	return labels
+}
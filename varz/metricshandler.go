@@ -0,0 +1,168 @@
+package varz
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// entry is one leaf metric discovered by walkVars.
+type entry struct {
+	// kind is "counter", "gauge", "labelmap" or "histogram", derived
+	// from the metric's expvar key (see Registry) or, for a
+	// *Histogram, its Go type; it is "" for a published expvar.Var
+	// that follows none of these conventions.
+	kind string
+	name string
+	v    expvar.Var
+}
+
+// MetricsHandler returns a http.Handler that renders every variable
+// published via expvar whose top-level name begins with prefix (pass ""
+// to include everything), as varz JSON by default, or in Prometheus text
+// exposition format when the request asks for it, either via
+// "?format=prometheus" or an Accept header preferring text/plain over
+// application/json, as Prometheus's own scraper sends.
+//
+// Each published expvar.Map is also walked one level deep, so that the
+// per-registry maps published by NewRegistry are flattened into
+// "registryname.metricname" entries. The "counter_", "gauge_" and
+// "labelmap_" prefix on a metric's own key (see Registry) says how to
+// render it; a *Histogram is recognised directly by type, regardless of
+// its key.
+func MetricsHandler(prefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entries := walkVars(prefix)
+		if wantsPrometheus(r) {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+			writePrometheus(w, entries)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		writeJSON(w, entries)
+	})
+}
+
+func wantsPrometheus(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "prometheus" {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "text/plain") && !strings.Contains(accept, "application/json")
+}
+
+func walkVars(prefix string) []entry {
+	var entries []entry
+	expvar.Do(func(kv expvar.KeyValue) {
+		if !strings.HasPrefix(kv.Key, prefix) {
+			return
+		}
+		if m, ok := kv.Value.(*expvar.Map); ok {
+			m.Do(func(inner expvar.KeyValue) {
+				entries = append(entries, classify(kv.Key+"."+inner.Key, inner.Key, inner.Value))
+			})
+			return
+		}
+		entries = append(entries, classify(kv.Key, kv.Key, kv.Value))
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries
+}
+
+func classify(fullName, leafName string, v expvar.Var) entry {
+	if _, ok := v.(*Histogram); ok {
+		return entry{kind: "histogram", name: fullName, v: v}
+	}
+	switch {
+	case strings.HasPrefix(leafName, "counter_"):
+		return entry{kind: "counter", name: fullName, v: v}
+	case strings.HasPrefix(leafName, "gauge_"):
+		return entry{kind: "gauge", name: fullName, v: v}
+	case strings.HasPrefix(leafName, "labelmap_"):
+		return entry{kind: "labelmap", name: fullName, v: v}
+	default:
+		return entry{kind: "", name: fullName, v: v}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, entries []entry) {
+	out := make(map[string]json.RawMessage, len(entries))
+	for _, e := range entries {
+		kind, _ := json.Marshal(e.kind)
+		out[e.name] = json.RawMessage(fmt.Sprintf(`{"kind":%s,"value":%s}`, kind, e.v.String()))
+	}
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+func writePrometheus(w http.ResponseWriter, entries []entry) {
+	for _, e := range entries {
+		name := prometheusName(e.name)
+		switch e.kind {
+		case "counter":
+			fmt.Fprintf(w, "# TYPE %s counter\n%s %s\n", name, name, e.v.String())
+		case "gauge":
+			fmt.Fprintf(w, "# TYPE %s gauge\n%s %s\n", name, name, e.v.String())
+		case "labelmap":
+			fmt.Fprintf(w, "# TYPE %s counter\n", name)
+			e.v.(*expvar.Map).Do(func(kv expvar.KeyValue) {
+				fmt.Fprintf(w, "%s{%s} %s\n", name, prometheusLabels(kv.Key), kv.Value.String())
+			})
+		case "histogram":
+			writePrometheusHistogram(w, name, e.v.(*Histogram))
+		default:
+			fmt.Fprintf(w, "# TYPE %s gauge\n%s %s\n", name, name, e.v.String())
+		}
+	}
+}
+
+func writePrometheusHistogram(w http.ResponseWriter, name string, h *Histogram) {
+	bounds, counts, total, sum := h.Snapshot()
+
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for i, bound := range bounds {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", name, formatMillis(bound), counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, total)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatMillis(sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, total)
+}
+
+// prometheusName maps an expvar/varz metric name, which may contain
+// characters Prometheus doesn't allow, onto a valid Prometheus metric
+// name.
+func prometheusName(name string) string {
+	return strings.NewReplacer(".", "_", "-", "_", ":", "_").Replace(name)
+}
+
+// prometheusLabels renders the Labels encoded in an expvar.Map key (see
+// Labels.String) as a Prometheus label list, e.g. `method="GET",route="/"`.
+func prometheusLabels(key string) string {
+	labels := parseLabels(key)
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=%q", name, labels[name])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// formatMillis formats d as a decimal number of milliseconds, the unit
+// used throughout this package's histograms.
+func formatMillis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
+}
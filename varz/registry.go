@@ -0,0 +1,173 @@
+// Package varz provides a named registry of counters, gauges and a
+// latency histogram, published via expvar so they appear at the standard
+// /debug/vars endpoint, and a MetricsHandler that renders the same data
+// as varz JSON or Prometheus text exposition format, for mounting at
+// /debug/varz and /metrics (see httpctx/debug).
+package varz
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"sp.com.au/exp/middleware"
+)
+
+// Registry is a named set of counters, gauges and a request-latency
+// histogram, published as a single expvar.Map so its contents show up at
+// /debug/vars (and, via MetricsHandler, at /debug/varz and /metrics)
+// under the name it was created with.
+//
+// Entries follow a "kind_name" naming convention, so that MetricsHandler
+// can tell a plain counter from a gauge from a labelled counter without
+// needing a type switch over every possible expvar.Var implementation:
+// keys published by Counter are prefixed "counter_", keys published by
+// Gauge are prefixed "gauge_", and keys published by the labelled
+// counters behind IncRequestCount are prefixed "labelmap_".
+type Registry struct {
+	vars expvar.Map
+
+	mu         sync.Mutex
+	counters   map[string]*expvar.Int
+	gauges     map[string]*expvar.Float
+	labelmaps  map[string]*expvar.Map
+	histograms map[string]*Histogram
+}
+
+var (
+	_ middleware.Registry          = (*Registry)(nil)
+	_ middleware.HistogramRegistry = (*Registry)(nil)
+)
+
+// RegistryOption configures NewRegistry. See WithLatencyBuckets.
+type RegistryOption func(*registryOptions)
+
+type registryOptions struct {
+	buckets []time.Duration
+}
+
+// WithLatencyBuckets sets the upper bounds used by the histogram behind
+// ObserveLatency, replacing DefaultBuckets.
+func WithLatencyBuckets(bounds ...time.Duration) RegistryOption {
+	return func(o *registryOptions) {
+		o.buckets = bounds
+	}
+}
+
+// NewRegistry creates a Registry and publishes it via expvar under name.
+// name must be unique for the lifetime of the process; like
+// expvar.Publish, NewRegistry panics if name has already been published.
+func NewRegistry(name string, opts ...RegistryOption) *Registry {
+	var o registryOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	r := &Registry{
+		counters:   make(map[string]*expvar.Int),
+		gauges:     make(map[string]*expvar.Float),
+		labelmaps:  make(map[string]*expvar.Map),
+		histograms: make(map[string]*Histogram),
+	}
+	r.vars.Init()
+	expvar.Publish(name, &r.vars)
+
+	r.histogram("http_request_duration_ms", o.buckets)
+
+	return r
+}
+
+// Counter returns the named counter, creating and publishing it (as
+// "counter_"+name) the first time it is asked for.
+func (r *Registry) Counter(name string) *expvar.Int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.counters[name]; ok {
+		return c
+	}
+	c := new(expvar.Int)
+	r.counters[name] = c
+	r.vars.Set("counter_"+name, c)
+	return c
+}
+
+// Gauge returns the named gauge, creating and publishing it (as
+// "gauge_"+name) the first time it is asked for.
+func (r *Registry) Gauge(name string) *expvar.Float {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if g, ok := r.gauges[name]; ok {
+		return g
+	}
+	g := new(expvar.Float)
+	r.gauges[name] = g
+	r.vars.Set("gauge_"+name, g)
+	return g
+}
+
+// Set sets the named gauge to v, creating it first if necessary.
+func (r *Registry) Set(name string, v float64) {
+	r.Gauge(name).Set(v)
+}
+
+// labelCounter returns the *expvar.Int for labels within the named
+// labelled counter (published as "labelmap_"+name), creating the
+// labelmap and/or the entry as needed.
+func (r *Registry) labelCounter(name string, labels Labels) *expvar.Int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.labelmaps[name]
+	if !ok {
+		m = new(expvar.Map)
+		m.Init()
+		r.labelmaps[name] = m
+		r.vars.Set("labelmap_"+name, m)
+	}
+
+	key := labels.String()
+	if v := m.Get(key); v != nil {
+		return v.(*expvar.Int)
+	}
+	c := new(expvar.Int)
+	m.Set(key, c)
+	return c
+}
+
+// histogram returns the named histogram, creating and publishing it the
+// first time it is asked for. bounds is only used the first time;
+// later calls return the histogram created the first time, regardless of
+// bounds.
+func (r *Registry) histogram(name string, bounds []time.Duration) *Histogram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h, ok := r.histograms[name]; ok {
+		return h
+	}
+	h := NewHistogram(bounds)
+	r.histograms[name] = h
+	r.vars.Set(name, h)
+	return h
+}
+
+// statusClass formats status as a Prometheus-style status class, such as
+// "2xx" or "5xx".
+func statusClass(status int) string {
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// IncRequestCount implements middleware.Registry, incrementing a labelled
+// counter keyed by method, route and the status class of status (e.g.
+// "2xx" rather than the precise code), published as
+// "labelmap_http_requests_total".
+func (r *Registry) IncRequestCount(method, route string, status int) {
+	labels := Labels{"method": method, "route": route, "status_class": statusClass(status)}
+	r.labelCounter("http_requests_total", labels).Add(1)
+}
+
+// ObserveLatency implements middleware.HistogramRegistry, recording d in
+// the registry's request-duration histogram.
+func (r *Registry) ObserveLatency(method, route string, status int, d time.Duration) {
+	r.histogram("http_request_duration_ms", nil).Observe(d)
+}
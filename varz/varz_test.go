@@ -0,0 +1,156 @@
+package varz_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sp.com.au/exp/varz"
+)
+
+func TestRegistryCounterPublishesUnderName(t *testing.T) {
+	reg := varz.NewRegistry("varz_test.counters")
+	reg.Counter("hits").Add(3)
+	reg.Counter("hits").Add(1)
+
+	v := expvar.Get("varz_test.counters")
+	if v == nil {
+		t.Fatal("expected the registry to be published via expvar")
+	}
+	if got := v.(*expvar.Map).Get("counter_hits").String(); got != "4" {
+		t.Errorf("got hits %s, want 4", got)
+	}
+}
+
+func TestRegistrySetGauge(t *testing.T) {
+	reg := varz.NewRegistry("varz_test.gauges")
+	reg.Set("queue_depth", 2.5)
+	reg.Set("queue_depth", 4)
+
+	v := expvar.Get("varz_test.gauges")
+	if got := v.(*expvar.Map).Get("gauge_queue_depth").String(); got != "4" {
+		t.Errorf("got queue_depth %s, want 4", got)
+	}
+}
+
+func TestRegistryIncRequestCountUsesStatusClass(t *testing.T) {
+	reg := varz.NewRegistry("varz_test.requests")
+	reg.IncRequestCount(http.MethodGet, "/widgets", http.StatusOK)
+	reg.IncRequestCount(http.MethodGet, "/widgets", http.StatusCreated)
+
+	v := expvar.Get("varz_test.requests")
+	m := v.(*expvar.Map).Get("labelmap_http_requests_total").(*expvar.Map)
+	if got := m.Get("method=GET,route=/widgets,status_class=2xx").String(); got != "2" {
+		t.Errorf("got count %s, want 2", got)
+	}
+}
+
+func TestRegistryIncRequestCountConcurrentNewLabelsDoNotDropCounts(t *testing.T) {
+	reg := varz.NewRegistry("varz_test.concurrent_requests")
+
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			reg.IncRequestCount(http.MethodGet, "/new-route", http.StatusOK)
+		}()
+	}
+	wg.Wait()
+
+	v := expvar.Get("varz_test.concurrent_requests")
+	m := v.(*expvar.Map).Get("labelmap_http_requests_total").(*expvar.Map)
+	if got := m.Get("method=GET,route=/new-route,status_class=2xx").String(); got != fmt.Sprint(n) {
+		t.Errorf("got count %s, want %d", got, n)
+	}
+}
+
+func TestRegistryObserveLatency(t *testing.T) {
+	reg := varz.NewRegistry("varz_test.latency")
+	reg.ObserveLatency(http.MethodGet, "/widgets", http.StatusOK, 5*time.Millisecond)
+
+	v := expvar.Get("varz_test.latency")
+	h := v.(*expvar.Map).Get("http_request_duration_ms")
+	if h == nil {
+		t.Fatal("expected the duration histogram to be published")
+	}
+	var data struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.Unmarshal([]byte(h.String()), &data); err != nil {
+		t.Fatalf("unmarshal histogram: %v", err)
+	}
+	if data.Count != 1 {
+		t.Errorf("got count %d, want 1", data.Count)
+	}
+}
+
+func TestHistogramObserveAndSnapshot(t *testing.T) {
+	h := varz.NewHistogram([]time.Duration{10 * time.Millisecond, 100 * time.Millisecond})
+	h.Observe(5 * time.Millisecond)
+	h.Observe(50 * time.Millisecond)
+	h.Observe(500 * time.Millisecond)
+
+	bounds, counts, total, sum := h.Snapshot()
+	if len(bounds) != 2 || counts[0] != 1 || counts[1] != 2 {
+		t.Errorf("got counts %v for bounds %v, want [1 2]", counts, bounds)
+	}
+	if total != 3 {
+		t.Errorf("got total %d, want 3", total)
+	}
+	if sum != 555*time.Millisecond {
+		t.Errorf("got sum %v, want 555ms", sum)
+	}
+}
+
+func TestMetricsHandlerJSON(t *testing.T) {
+	reg := varz.NewRegistry("varz_test.handler_json")
+	reg.Counter("hits").Add(1)
+
+	r := httptest.NewRequest(http.MethodGet, "/debug/varz", nil)
+	w := httptest.NewRecorder()
+	varz.MetricsHandler("varz_test.handler_json").ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	var out map[string]struct {
+		Kind  string          `json:"kind"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	m, ok := out["varz_test.handler_json.counter_hits"]
+	if !ok {
+		t.Fatalf("expected an entry for the hits counter, got %v", out)
+	}
+	if m.Kind != "counter" || string(m.Value) != "1" {
+		t.Errorf("got kind %q value %s, want counter 1", m.Kind, m.Value)
+	}
+}
+
+func TestMetricsHandlerPrometheus(t *testing.T) {
+	reg := varz.NewRegistry("varz_test.handler_prom")
+	reg.IncRequestCount(http.MethodGet, "/widgets", http.StatusOK)
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	r.Header.Set("Accept", "text/plain")
+	w := httptest.NewRecorder()
+	varz.MetricsHandler("varz_test.handler_prom").ServeHTTP(w, r)
+
+	body := w.Body.String()
+	if !strings.Contains(body, `method="GET"`) || !strings.Contains(body, `status_class="2xx"`) {
+		t.Errorf("expected labelled Prometheus output, got:\n%s", body)
+	}
+	if !strings.Contains(body, "# TYPE") {
+		t.Errorf("expected a # TYPE line, got:\n%s", body)
+	}
+}
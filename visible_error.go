@@ -0,0 +1,32 @@
+package httpctx
+
+import (
+	"net/http"
+
+	"sp.com.au/exp/errs"
+)
+
+// VisibleError returns an error whose message (publicMsg) is safe to
+// show directly to the HTTP client, together with the HTTP status code
+// to respond with. wrapped, the original error, stays available via
+// errors.Unwrap (and so to the SetOnError hook) for server-side diagnostics, but is
+// never written to the response. It is a thin, root-package convenience
+// around errs.Visible, named for the common "vizerror" pattern of a
+// public-safe error distinct from its cause.
+func VisibleError(code int, publicMsg string, wrapped error) error {
+	return errs.Visible(publicMsg, code, wrapped)
+}
+
+// onError, if set with SetOnError, is called by DefaultErrorEncoder with
+// the original error returned by a Handler, before any errs.Visible
+// message substitution, so that a program can forward it to an
+// error-tracking service or its own logging without that detail reaching
+// the client.
+var onError func(r *http.Request, err error)
+
+// SetOnError installs f as the hook DefaultErrorEncoder calls with every
+// error it turns into a HTTP response (see onError). Passing nil disables
+// the hook. This should normally be called once, during program startup.
+func SetOnError(f func(r *http.Request, err error)) {
+	onError = f
+}
@@ -0,0 +1,54 @@
+package httpctx
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestVisibleErrorHidesWrappedMessage(t *testing.T) {
+	cause := errors.New("database connection refused at 10.0.0.5:5432")
+	err := VisibleError(http.StatusServiceUnavailable, "try again later", cause)
+
+	rr := httptest.NewRecorder()
+	sendError(rr, &http.Request{URL: &url.URL{}, Header: http.Header{}}, err)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "try again later") {
+		t.Errorf("expected the public message in the response, got %q", body)
+	}
+	if strings.Contains(body, "10.0.0.5") {
+		t.Errorf("expected the wrapped cause to stay out of the response, got %q", body)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("expected errors.Is to find the wrapped cause")
+	}
+}
+
+func TestDefaultErrorEncoderCallsOnError(t *testing.T) {
+	defer SetOnError(nil)
+
+	cause := errors.New("boom")
+	wantErr := VisibleError(http.StatusInternalServerError, "internal error", cause)
+
+	var got error
+	SetOnError(func(r *http.Request, err error) {
+		got = err
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	DefaultErrorEncoder(r.Context(), httptest.NewRecorder(), r, wantErr)
+
+	if got != wantErr {
+		t.Errorf("got %v passed to OnError, want %v", got, wantErr)
+	}
+	if !errors.Is(got, cause) {
+		t.Error("expected the error passed to OnError to still wrap cause")
+	}
+}